@@ -0,0 +1,151 @@
+package orchestrator
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/entur/go-orchestrator/oresources"
+)
+
+// -----------------------
+// CLI
+// -----------------------
+
+// Exit codes used by RunCLI.
+const (
+	CLIExitSuccess = 0 // The result code was 'success' or 'noop'
+	CLIExitFailure = 1 // The result code was 'failure' or 'error'
+	CLIExitUsage   = 2 // The flags were invalid, or a manifest could not be read
+)
+
+// Authentication modes of the IAM Lookup client used by RunCLI, see the -iam-auth flag.
+const (
+	CLIIAMAuthDiscover = "discover" // See oresources.AuthDiscover
+	CLIIAMAuthIDToken  = "idtoken"  // See oresources.AuthIDToken
+	CLIIAMAuthNone     = "none"     // See oresources.AuthNone
+)
+
+// RunCLI runs a single request against the sub-orchestrator from the command line, and returns the exit code.
+// It is intended to be called from a main package, to try out manifests locally:
+//
+//	func main() {
+//		os.Exit(orchestrator.RunCLI(&MySubOrchestrator{}))
+//	}
+//
+//	go run ./cmd/mysuborchestrator -action apply -manifest .entur/app.yaml
+//
+// Manifests can be written in either YAML or JSON. Run with -h for a list of all flags.
+func RunCLI(so Orchestrator) int {
+	return runCLI(context.Background(), so, os.Args[0], os.Args[1:], os.Stdout, os.Stderr)
+}
+
+func runCLI(ctx context.Context, so Orchestrator, name string, args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	action := fs.String("action", string(DefaultMockAction), fmt.Sprintf("The user action, one of %s", joinQuoted(Actions())))
+	manifestPath := fs.String("manifest", "", "Path to the new manifest (YAML or JSON)")
+	oldManifestPath := fs.String("old", "", "Path to the old manifest (YAML or JSON), if the manifest is being changed")
+	sender := fs.String("sender", DefaultMockUsername, "The GitHub username of the sender")
+	senderEmail := fs.String("sender-email", DefaultMockUserEmail, "The verified email of the sender")
+	permission := fs.String("permission", string(DefaultMockUserPermission), "The repository permission of the sender")
+	repository := fs.String("repository", DefaultMockRepositoryFullName, "The full name of the repository the manifest is located in")
+	iamURL := fs.String("iam-url", "", "The URL of the IAM Lookup resource, e.g. a mock server started with cmd/mock-iam-lookup")
+	iamAuth := fs.String("iam-auth", CLIIAMAuthDiscover, fmt.Sprintf("How requests to the IAM Lookup resource are authenticated, one of %s", joinQuoted(cliIAMAuths)))
+	iamToken := fs.String("iam-token", "", "A bearer token for the IAM Lookup resource, e.g. the output of 'gcloud auth print-identity-token'. Overrides -iam-auth")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return CLIExitUsage
+	}
+
+	if *manifestPath == "" {
+		fmt.Fprintln(stderr, "flag -manifest is required")
+		fs.Usage()
+		return CLIExitUsage
+	}
+	if !Action(*action).IsValid() {
+		fmt.Fprintf(stderr, "flag -action '%s' is not valid, expected one of %s\n", *action, joinQuoted(Actions()))
+		return CLIExitUsage
+	}
+
+	auth, err := cliIAMAuth(*iamAuth, *iamToken)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return CLIExitUsage
+	}
+
+	opts := []MockRequestOption{
+		WithAction(Action(*action)),
+		WithSender(Sender{
+			Username:   *sender,
+			Email:      *senderEmail,
+			Type:       DefaultMockSenderType,
+			Permission: RepositoryPermission(*permission),
+		}),
+		WithRepositoryFullName(*repository),
+	}
+
+	if *iamURL != "" {
+		client, err := oresources.NewIAMLookupClientWithAuth(ctx, *iamURL, auth)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return CLIExitUsage
+		}
+		ctx = oresources.WithIAMLookup(ctx, client)
+		opts = append(opts, WithIAMEndpoint(*iamURL))
+	}
+
+	if *oldManifestPath != "" {
+		oldManifest, _, err := ReadManifestFile(*oldManifestPath)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return CLIExitUsage
+		}
+		opts = append(opts, WithOldManifest(oldManifest))
+	}
+
+	req, err := NewMockRequestFromFile(*manifestPath, opts...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return CLIExitUsage
+	}
+
+	result := Process(ctx, so, req)
+	code := result.Code()
+
+	fmt.Fprintf(stdout, "Result: %s\n\n%s\n", code, result.Output())
+	if errs := result.Errors(); len(errs) > 0 {
+		fmt.Fprintln(stderr, "\nInternal errors:")
+		for _, err := range errs {
+			fmt.Fprintf(stderr, "- %s\n", err)
+		}
+	}
+
+	if code == ResultCodeSuccess || code == ResultCodeNoop {
+		return CLIExitSuccess
+	}
+	return CLIExitFailure
+}
+
+var cliIAMAuths = []string{CLIIAMAuthDiscover, CLIIAMAuthIDToken, CLIIAMAuthNone}
+
+func cliIAMAuth(mode string, token string) (oresources.ClientAuth, error) {
+	if token != "" {
+		return oresources.AuthBearerToken(token), nil
+	}
+
+	switch mode {
+	case CLIIAMAuthDiscover:
+		return oresources.AuthDiscover(), nil
+	case CLIIAMAuthIDToken:
+		return oresources.AuthIDToken(), nil
+	case CLIIAMAuthNone:
+		return oresources.AuthNone(), nil
+	default:
+		return nil, fmt.Errorf("flag -iam-auth '%s' is not valid, expected one of %s", mode, joinQuoted(cliIAMAuths))
+	}
+}
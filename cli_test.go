@@ -0,0 +1,138 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/entur/go-orchestrator/oresources"
+)
+
+func TestRunCLI(t *testing.T) {
+	iamServer, err := oresources.NewMockIAMLookupServer(
+		oresources.WithUserGroups("someone@entur.io", []string{"team-a"}),
+		oresources.WithRequiredBearerToken("mocktoken"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(iamServer.Handler())
+	t.Cleanup(httpServer.Close)
+
+	lookupGroups := func(ctx context.Context, req Request, r *Result) error {
+		client, ok := oresources.IAMLookup(ctx)
+		if !ok {
+			return errors.New("no iam lookup client")
+		}
+		groups, err := client.EntraIDUserGroups(ctx, req.Sender.Email)
+		if err != nil {
+			return err
+		}
+		r.Fail(strings.Join(groups, ","))
+		return nil
+	}
+
+	type Expected struct {
+		exit   int
+		stdout string
+		stderr string
+	}
+
+	type Test struct {
+		title    string
+		manifest string
+		args     []string
+		action   func(context.Context, Request, *Result) error
+		expected Expected
+	}
+
+	var tests = []Test{
+		{
+			title:    "yaml manifest",
+			manifest: "apiVersion: orchestrator.entur.io/test/v1\nkind: Test\n",
+			args:     []string{"-action", "apply"},
+			expected: Expected{exit: CLIExitSuccess, stdout: "Result: success"},
+		},
+		{
+			title:    "json manifest with request flags",
+			manifest: `{"apiVersion": "orchestrator.entur.io/test/v1", "kind": "Test"}`,
+			args:     []string{"-sender", "someone", "-sender-email", "someone@entur.io", "-permission", "read", "-repository", "entur/somerepo"},
+			action: func(_ context.Context, req Request, r *Result) error {
+				r.Fail(req.Sender.Username + " " + req.Sender.Email + " " + string(req.Sender.Permission) + " " + req.Origin.Repository.Name)
+				return nil
+			},
+			expected: Expected{exit: CLIExitFailure, stdout: "someone someone@entur.io read somerepo"},
+		},
+		{
+			title:    "iam lookup against a mock server",
+			manifest: "apiVersion: orchestrator.entur.io/test/v1\nkind: Test\n",
+			args:     []string{"-sender-email", "someone@entur.io", "-iam-url", httpServer.URL, "-iam-token", "mocktoken"},
+			action:   lookupGroups,
+			expected: Expected{exit: CLIExitFailure, stdout: "team-a"},
+		},
+		{
+			title:    "iam lookup with the wrong authentication",
+			manifest: "apiVersion: orchestrator.entur.io/test/v1\nkind: Test\n",
+			args:     []string{"-sender-email", "someone@entur.io", "-iam-url", httpServer.URL, "-iam-auth", "none"},
+			action:   lookupGroups,
+			expected: Expected{exit: CLIExitFailure, stdout: "Result: error", stderr: oresources.ErrUnauthorized.Error()},
+		},
+		{
+			title:    "invalid iam auth",
+			manifest: "apiVersion: orchestrator.entur.io/test/v1\nkind: Test\n",
+			args:     []string{"-iam-auth", "yolo"},
+			expected: Expected{exit: CLIExitUsage, stderr: "flag -iam-auth 'yolo' is not valid"},
+		},
+		{
+			title:    "internal errors",
+			manifest: "apiVersion: orchestrator.entur.io/test/v1\nkind: Test\n",
+			action: func(context.Context, Request, *Result) error {
+				return os.ErrNotExist
+			},
+			expected: Expected{exit: CLIExitFailure, stdout: "Result: error", stderr: os.ErrNotExist.Error()},
+		},
+		{
+			title:    "invalid action",
+			manifest: "apiVersion: orchestrator.entur.io/test/v1\nkind: Test\n",
+			args:     []string{"-action", "yolo"},
+			expected: Expected{exit: CLIExitUsage, stderr: "flag -action 'yolo' is not valid"},
+		},
+		{
+			title:    "empty manifest",
+			expected: Expected{exit: CLIExitUsage, stderr: "is empty"},
+		},
+	}
+
+	for _, test := range tests {
+		tmp := test
+		t.Run(tmp.title, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "manifest.yaml")
+			err := os.WriteFile(path, []byte(tmp.manifest), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			so := &testSO{handlers: []ManifestHandler{&testHandler{action: tmp.action}}}
+			args := append([]string{"-manifest", path}, tmp.args...)
+
+			var stdout, stderr bytes.Buffer
+			exit := runCLI(context.Background(), so, "test", args, &stdout, &stderr)
+			if exit != tmp.expected.exit {
+				t.Errorf("exit code does not match expected value\ngot: %d\nwant: %d\nstderr: %s", exit, tmp.expected.exit, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tmp.expected.stdout) {
+				t.Errorf("stdout does not contain expected value\ngot: %s\nwant: %s", stdout.String(), tmp.expected.stdout)
+			}
+			if !strings.Contains(stderr.String(), tmp.expected.stderr) {
+				t.Errorf("stderr does not contain expected value\ngot: %s\nwant: %s", stderr.String(), tmp.expected.stderr)
+			}
+		})
+	}
+}
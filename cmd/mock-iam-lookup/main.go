@@ -0,0 +1,77 @@
+// Command mock-iam-lookup serves a mock IAM Lookup resource, for local development against a running sub-orchestrator.
+// The apps, projects, user roles and groups are loaded from a YAML or JSON fixture file, which is reloaded on changes:
+//
+//	go run github.com/entur/go-orchestrator/cmd/mock-iam-lookup -fixture iam.yaml -port 8001
+//
+// See oresources.MockIAMFixture for the fixture format.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/entur/go-logging"
+	"github.com/entur/go-orchestrator/oresources"
+)
+
+const defaultPort = 8001
+
+const (
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("mock-iam-lookup", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	fixture := fs.String("fixture", "", "Path to the fixture file (YAML or JSON)")
+	port := fs.Int("port", defaultPort, "The port to listen on")
+	err := fs.Parse(args)
+	if err != nil {
+		return exitUsage
+	}
+	if *fixture == "" {
+		_, _ = fmt.Fprintln(stderr, "the -fixture flag is required")
+		fs.Usage()
+		return exitUsage
+	}
+
+	logger := logging.New()
+	server, err := oresources.NewMockIAMLookupServer(
+		oresources.WithPort(*port),
+		oresources.WithFixtureFile(*fixture),
+		oresources.WithMockLogger(logger),
+	)
+	if err != nil {
+		logger.Error().Err(err).Msg("Unable to create the mock IAM Lookup server")
+		return exitFailure
+	}
+
+	err = server.Start()
+	if err != nil {
+		logger.Error().Err(err).Msg("Unable to start the mock IAM Lookup server")
+		return exitFailure
+	}
+	logger.Info().Msgf("Serving mock IAM Lookup at %s, using fixture '%s'", server.URL(), *fixture)
+
+	<-ctx.Done()
+	err = server.Stop()
+	if err != nil {
+		logger.Error().Err(err).Msg("Unable to stop the mock IAM Lookup server")
+		return exitFailure
+	}
+	return 0
+}
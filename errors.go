@@ -0,0 +1,54 @@
+package orchestrator
+
+import (
+	"errors"
+	"time"
+)
+
+// -----------------------
+// Errors
+// -----------------------
+
+const DefaultMaxDeliveryAttempts = 5 // Default maximum number of delivery attempts for events failing with a retryable error.
+
+const DefaultMaxRedeliveryAge = 10 * time.Minute // Default maximum age of events failing with a retryable error, if their delivery attempt is unknown.
+
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// Retryable marks an error as transient, e.g. a timeout or an unavailable dependency.
+// If a handler or middleware returns a retryable error, the CloudEvent handler requests a redelivery of the event
+// instead of responding with an error result, until the maximum number of delivery attempts has been reached.
+// All other errors are considered permanent.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{err: err}
+}
+
+// IsRetryable reports whether any error in err's tree has been marked as retryable.
+func IsRetryable(err error) bool {
+	var target *retryableError
+	return errors.As(err, &target)
+}
+
+// allRetryable reports whether there is at least one error, and all of them are retryable.
+// A single permanent error is enough for a redelivery to be pointless.
+func allRetryable(errs []error) bool {
+	for _, err := range errs {
+		if !IsRetryable(err) {
+			return false
+		}
+	}
+	return len(errs) > 0
+}
@@ -5,30 +5,81 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	"cloud.google.com/go/pubsub/v2"
 	cloudevent "github.com/cloudevents/sdk-go/v2/event"
 	"github.com/entur/go-logging"
+	"github.com/entur/go-orchestrator/oresources"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // -----------------------
 // Cloud Event
 // -----------------------
 
-type PubSubMessageAttributes struct{}
+type PubSubMessageAttributes map[string]string
 
 type PubSubMessage struct {
 	ID          string                  `json:"messageId"`
-	PublishTime string                  `json:"publishTime"`
+	PublishTime time.Time               `json:"publishTime"`
 	Attributes  PubSubMessageAttributes `json:"attributes"`
+	OrderingKey string                  `json:"orderingKey,omitempty"`
 	Data        []byte                  `json:"data"`
 }
 
+// UnmarshalJSON tolerates an empty, missing or malformed publishTime, leaving PublishTime as the zero time,
+// instead of rejecting the whole event.
+func (m *PubSubMessage) UnmarshalJSON(data []byte) error {
+	type message PubSubMessage
+	aux := struct {
+		*message
+		PublishTime json.RawMessage `json:"publishTime"`
+	}{message: (*message)(m)}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	m.PublishTime = time.Time{}
+	var publishTime string
+	if json.Unmarshal(aux.PublishTime, &publishTime) == nil && publishTime != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, publishTime)
+		if err == nil {
+			m.PublishTime = parsed
+		}
+	}
+
+	return nil
+}
+
 type CloudEventData struct {
-	Subscription string
-	Message      PubSubMessage
+	Subscription    string
+	Message         PubSubMessage
+	DeliveryAttempt int `json:"deliveryAttempt,omitempty"` // Only set by Pub/Sub if the subscription has a dead letter policy, otherwise 0
+}
+
+type envelopeCtxKey struct{}
+
+// Retrieve the Pub/Sub envelope (subscription, message id, publish time, attributes, ordering key and delivery attempt)
+// which the current request was delivered in. The second return value is false if the request did not
+// originate from a CloudEvent, e.g. when calling Process directly.
+func EnvelopeCtx(ctx context.Context) (CloudEventData, bool) {
+	data, ok := ctx.Value(envelopeCtxKey{}).(CloudEventData)
+	return data, ok
+}
+
+// Attach a Pub/Sub envelope to the given context, making it available through EnvelopeCtx.
+// Mostly useful for testing handlers which depend on the envelope without going through the CloudEvent handler.
+func WithEnvelope(ctx context.Context, data CloudEventData) context.Context {
+	return context.WithValue(ctx, envelopeCtxKey{}, data)
 }
 
 func UnmarshalCloudEvent(e cloudevent.Event, v any) error {
@@ -46,9 +97,21 @@ func UnmarshalCloudEvent(e cloudevent.Event, v any) error {
 // -----------------------
 
 type HandlerConfig struct {
-	client    *pubsub.Client
-	clientSet bool
-	logger    *zerolog.Logger
+	client         *pubsub.Client
+	clientSet      bool
+	logger         *zerolog.Logger
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagator     propagation.TextMapPropagator
+	maxAttempts    int
+	maxAge         time.Duration
+	publishRetry   *PublishRetryPolicy
+	ordering       bool
+	maxPublishers  int
+	iamLookupPool  *oresources.IAMLookupPool
+	iamLookupAuth  oresources.ClientAuth
+	clientPool     *oresources.ClientPool
+	resources      map[string]any
 }
 
 type HandlerOption func(*HandlerConfig)
@@ -66,86 +129,433 @@ func WithCustomPubSubClient(client *pubsub.Client) HandlerOption {
 	}
 }
 
-func NewCloudEventHandler(so Orchestrator, opts ...HandlerOption) func(context.Context, cloudevent.Event) error {
+// Use a custom TracerProvider for the spans created when handling events.
+// Defaults to the globally registered TracerProvider.
+func WithTracerProvider(tp trace.TracerProvider) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.tracerProvider = tp
+	}
+}
+
+// Use a custom MeterProvider for the metrics recorded when handling events.
+// Defaults to the globally registered MeterProvider. See NewPrometheusMeterProvider for a Prometheus-compatible provider.
+func WithMeterProvider(mp metric.MeterProvider) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.meterProvider = mp
+	}
+}
+
+// Use a custom propagator for extracting and injecting trace context in Pub/Sub message attributes.
+// Defaults to W3C Trace Context.
+func WithTextMapPropagator(propagator propagation.TextMapPropagator) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.propagator = propagator
+	}
+}
+
+// Set the maximum number of delivery attempts for events failing with a retryable error.
+// When reached, an error result is sent as the response instead of requesting another redelivery.
+// Defaults to DefaultMaxDeliveryAttempts.
+//
+// Note: The delivery attempt is only known if the Pub/Sub subscription has a dead letter policy.
+// Otherwise, events are instead redelivered until they are older than the maximum age, see WithMaxRedeliveryAge.
+func WithMaxDeliveryAttempts(attempts int) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.maxAttempts = attempts
+	}
+}
+
+// Set the maximum age of events failing with a retryable error, measured from their publish time, for subscriptions
+// without a dead letter policy. When reached, an error result is sent as the response instead of requesting another
+// redelivery. Defaults to DefaultMaxRedeliveryAge.
+func WithMaxRedeliveryAge(age time.Duration) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.maxAge = age
+	}
+}
+
+// Set how failed response publishes are retried. Defaults to DefaultPublishRetryPolicy.
+func WithPublishRetryPolicy(policy PublishRetryPolicy) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.publishRetry = &policy
+	}
+}
+
+// Publish responses with the request ContextID as the ordering key, such that responses
+// belonging to the same context are delivered in order. Requires message ordering to be enabled on the subscription.
+func WithOrderingKeys() HandlerOption {
+	return func(c *HandlerConfig) {
+		c.ordering = true
+	}
+}
+
+// Set the maximum number of idle response topic publishers kept open. Defaults to DefaultMaxPublishers.
+func WithMaxPublishers(publishers int) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.maxPublishers = publishers
+	}
+}
+
+// Set the pool from which IAM Lookup clients are taken, one per req.Resources.IAMLookup.URL, and attached to the request
+// context (see oresources.IAMLookup). Useful for creating the clients with custom options, or sharing them between handlers.
+func WithIAMLookupPool(pool *oresources.IAMLookupPool) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.iamLookupPool = pool
+	}
+}
+
+// Set how the IAM Lookup clients created by the handler authenticate their requests, ignored if WithIAMLookupPool is set.
+// Defaults to oresources.AuthDiscover, use oresources.AuthIDToken to fail instead when no id token credentials are found.
+func WithIAMLookupAuth(auth oresources.ClientAuth) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.iamLookupAuth = auth
+	}
+}
+
+// Attach the given IAM Lookup client to every request context, regardless of req.Resources.IAMLookup.URL.
+// Mostly useful for testing handlers against a mock server, see oresources.NewMockIAMLookupServer.
+func WithIAMLookupClient(client *oresources.IAMLookupClient) HandlerOption {
+	return WithResourceClient(oresources.ResourceIAMLookup, client)
+}
+
+// Set the pool from which the clients created by the factories of a sub-orchestrator implementing ResourceClients are
+// taken, one per resource name and URL. Defaults to a pool per handler.
+func WithResourceClientPool(pool *oresources.ClientPool) HandlerOption {
+	return func(c *HandlerConfig) {
+		c.clientPool = pool
+	}
+}
+
+// Attach the given client for the named resource to every request context, regardless of req.Resources.
+// Mostly useful for replacing resource clients with fakes in tests, see oresources.Client.
+func WithResourceClient(name string, client any) HandlerOption {
+	return func(c *HandlerConfig) {
+		if c.resources == nil {
+			c.resources = map[string]any{}
+		}
+		c.resources[name] = client
+	}
+}
+
+// ErrHandlerClosed is returned when an event is received after the Handler has been closed.
+var ErrHandlerClosed = errors.New("handler is closed")
+
+// The Handler type handles Platform Orchestrator CloudEvents, and publishes the responses.
+type Handler struct {
+	so         Orchestrator
+	registry   *handlerRegistry
+	logger     zerolog.Logger
+	client     *pubsub.Client
+	ownsClient bool
+	tracer     trace.Tracer
+	instr      *instruments
+	propagator propagation.TextMapPropagator
+	ordering   bool
+	retry      PublishRetryPolicy
+	resources  *resourceClients
+
+	maxAttempts int
+	maxAge      time.Duration
+	publishers  *publisherCache
+
+	mu       sync.Mutex
+	closed   bool
+	inflight sync.WaitGroup
+}
+
+// newHandler always returns a usable handler, even if an error is returned.
+func newHandler(so Orchestrator, opts ...HandlerOption) (*Handler, error) {
+	var errs error
+
 	cfg := &HandlerConfig{}
 	for _, opt := range opts {
 		opt(cfg)
 	}
 
-	var parentLogger zerolog.Logger
+	h := &Handler{
+		so:          so,
+		propagator:  cfg.propagator,
+		ordering:    cfg.ordering,
+		retry:       DefaultPublishRetryPolicy,
+		maxAttempts: cfg.maxAttempts,
+		maxAge:      cfg.maxAge,
+		resources: &resourceClients{
+			iamLookupPool: cfg.iamLookupPool,
+			pool:          cfg.clientPool,
+			overrides:     cfg.resources,
+		},
+	}
+	if h.resources.iamLookupPool == nil {
+		auth := cfg.iamLookupAuth
+		if auth == nil {
+			auth = oresources.AuthDiscover()
+		}
+		h.resources.iamLookupPool = oresources.NewIAMLookupPoolWithAuth(auth)
+	}
+	if h.resources.pool == nil {
+		h.resources.pool = oresources.NewClientPool()
+	}
+
 	if cfg.logger != nil {
-		parentLogger = *cfg.logger
+		h.logger = *cfg.logger
 	} else {
-		parentLogger = logging.New()
+		h.logger = logging.New()
+	}
+
+	// Index the manifest handlers once, instead of for every request
+	err := ValidateOrchestrator(so)
+	if err != nil {
+		errs = errors.Join(errs, err)
+	}
+	h.registry = &handlerRegistry{}
+	if so != nil {
+		h.registry = newHandlerRegistry(so.Handlers())
 	}
 
-	var client *pubsub.Client
 	if cfg.clientSet {
-		client = cfg.client
+		h.client = cfg.client
 	} else {
-		client, _ = pubsub.NewClient(context.Background(), "unusedID")
+		client, err := pubsub.NewClient(context.Background(), "unusedID")
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("unable to create pubsub client: %w", err))
+		}
+		h.client = client
+		h.ownsClient = client != nil
 	}
 
-	publishers := map[string]*pubsub.Publisher{}
-	mu := sync.Mutex{}
-
-	parentLogger.Debug().Msg("Created a new CloudEventHandler")
-	return func(ctx context.Context, e cloudevent.Event) error {
-		logger := parentLogger.With().Logger()
-
-		var req Request
+	tracerProvider := cfg.tracerProvider
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+	h.tracer = tracerProvider.Tracer(tracerName)
 
-		err := UnmarshalCloudEvent(e, &req)
+	h.instr = globalInstruments()
+	if cfg.meterProvider != nil {
+		instr, err := newInstruments(cfg.meterProvider)
 		if err != nil {
-			logger.Error().Err(err).Msg("Encountered an error when unmarshalling CloudEvent to Request")
-			return err
+			h.logger.Warn().Err(err).Msg("Unable to create all metric instruments, some metrics will not be recorded")
 		}
+		h.instr = instr
+	}
 
-		logger.UpdateContext(func(c zerolog.Context) zerolog.Context {
-			return c.Int("gorch_github_user_id", req.Sender.ID).
-				Str("gorch_context_id", req.Metadata.ContextID).
-				Str("gorch_request_id", req.Metadata.RequestID).
-				Str("gorch_file_name", req.Origin.FileName).
-				Str("gorch_action", string(req.Action))
-		})
+	if h.propagator == nil {
+		h.propagator = propagation.TraceContext{}
+	}
+
+	if h.maxAttempts <= 0 {
+		h.maxAttempts = DefaultMaxDeliveryAttempts
+	}
+	if h.maxAge <= 0 {
+		h.maxAge = DefaultMaxRedeliveryAge
+	}
+
+	if cfg.publishRetry != nil {
+		h.retry = *cfg.publishRetry
+	}
+	if h.retry.MaxAttempts <= 0 {
+		h.retry.MaxAttempts = 1
+	}
+
+	maxPublishers := cfg.maxPublishers
+	if maxPublishers <= 0 {
+		maxPublishers = DefaultMaxPublishers
+	}
+	h.publishers = newPublisherCache(h.client, maxPublishers, h.ordering)
+
+	h.logger.Debug().Msg("Created a new CloudEventHandler")
+	return h, errs
+}
+
+// NewHandler returns a new Handler for the given sub-orchestrator.
+// Unlike NewCloudEventHandler, configuration problems such as an invalid sub-orchestrator (see ValidateOrchestrator)
+// or a failure to create the Pub/Sub client are all reported as an error, instead of surfacing when handling events.
+func NewHandler(so Orchestrator, opts ...HandlerOption) (*Handler, error) {
+	h, err := newHandler(so, opts...)
+	if err != nil {
+		// The handler is discarded, so release the Pub/Sub client it may have created
+		_ = h.Close(context.Background())
+		return nil, err
+	}
+	return h, nil
+}
+
+// NewCloudEventHandler returns a function handling Platform Orchestrator CloudEvents for the given sub-orchestrator.
+// See NewHandler for a variant which reports configuration problems, and can be closed.
+func NewCloudEventHandler(so Orchestrator, opts ...HandlerOption) func(context.Context, cloudevent.Event) error {
+	h, err := newHandler(so, opts...)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Encountered an error when creating the CloudEventHandler")
+	}
+	return h.Handle
+}
+
+// Handle processes a single Platform Orchestrator CloudEvent, and publishes the response.
+func (h *Handler) Handle(ctx context.Context, e cloudevent.Event) (err error) {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return ErrHandlerClosed
+	}
+	h.inflight.Add(1)
+	h.mu.Unlock()
+	defer h.inflight.Done()
+
+	logger := h.logger.With().Logger()
+
+	var data CloudEventData
+	var processErr error
+
+	err = e.DataAs(&data)
+	if err != nil {
+		logger.Error().Err(err).Msg("Encountered an error when unmarshalling CloudEvent to Request")
+		return err
+	}
 
-		ctx = logger.WithContext(ctx)
-		result := Process(ctx, so, &req)
-		err = errors.Join(result.errs...)
+	// Continue the trace started by the Platform Orchestrator, if any
+	ctx = h.propagator.Extract(ctx, propagation.MapCarrier(data.Message.Attributes))
+	ctx = WithEnvelope(ctx, data)
+	ctx = context.WithValue(ctx, instrumentsCtxKey{}, h.instr)
+	ctx, span := h.tracer.Start(ctx, SpanNameHandle, trace.WithSpanKind(trace.SpanKindConsumer))
+	defer func() {
+		endSpan(span, errors.Join(processErr, err))
+	}()
 
+	// Reject malformed request envelopes before any middlewares or handlers are run
+	_, unmarshalSpan := h.tracer.Start(ctx, SpanNameUnmarshal)
+	req, processErr := DecodeRequest(data.Message.Data)
+	if processErr == nil {
+		processErr = ValidateRequest(req)
+	}
+	endSpan(unmarshalSpan, processErr)
+	if processErr != nil {
+		// A malformed request will never decode successfully, so there is no point in requesting a redelivery.
+		// Respond with an internal error instead, so that the Platform Orchestrator is not left waiting.
+		responseTopic, metadata := decodeEnvelope(data.Message.Data)
 		logger.UpdateContext(func(c zerolog.Context) zerolog.Context {
-			return c.Interface("gorch_result_summary", result.summary).
-				Interface("gorch_result_creations", result.creations).
-				Interface("gorch_result_updates", result.updates).
-				Interface("gorch_result_deletions", result.deletions)
+			return c.Str("gorch_context_id", metadata.ContextID).
+				Str("gorch_request_id", metadata.RequestID)
 		})
-		ctx = logger.WithContext(ctx)
-
-		if client == nil {
-			logger.Warn().Msg("Pubsub client is set to null, no responses will be sent")
-		} else {
-			mu.Lock()
-			topic := req.ResponseTopic
-			publisher, ok := publishers[topic]
-			if !ok {
-				publisher = client.Publisher(topic)
-				publishers[topic] = publisher
-			}
-			mu.Unlock()
-
-			var res = Response{
-				APIVersion: APIVersionOrchestratorResponseV1,
-				Metadata:   req.Metadata,
-				ResultCode: result.Code(),
-				Output:     base64.StdEncoding.EncodeToString([]byte(result.Output())),
-			}
-
-			err = errors.Join(err, respond(ctx, publisher, &res))
-		}
+		logger.Error().Err(processErr).Msg("Encountered an error when unmarshalling CloudEvent to Request")
 
-		if err != nil {
-			logger.Error().Err(err).Msg("Encountered an error during the handling of the Request")
+		result := &Result{errs: []error{processErr}}
+		return h.publish(logger.WithContext(ctx), responseTopic, metadata, result)
+	}
+	span.SetAttributes(requestAttributes(req)...)
+
+	logger.UpdateContext(func(c zerolog.Context) zerolog.Context {
+		return c.Int("gorch_github_user_id", req.Sender.ID).
+			Str("gorch_context_id", req.Metadata.ContextID).
+			Str("gorch_request_id", req.Metadata.RequestID).
+			Str("gorch_file_name", req.Origin.FileName).
+			Str("gorch_action", string(req.Action))
+	})
+
+	ctx = logger.WithContext(ctx)
+	ctx = h.resources.attach(ctx, h.so, req)
+	result := processRequest(ctx, h.so, h.registry, req)
+	processErr = errors.Join(result.errs...)
+	span.SetAttributes(AttributeResultCode.String(string(result.Code())))
+
+	logger.UpdateContext(func(c zerolog.Context) zerolog.Context {
+		return c.Interface("gorch_result_summary", result.summary).
+			Interface("gorch_result_creations", result.creations).
+			Interface("gorch_result_updates", result.updates).
+			Interface("gorch_result_deletions", result.deletions)
+	})
+	ctx = logger.WithContext(ctx)
+
+	// Only request a redelivery of the event if the error is marked as retryable, and we haven't reached the maximum number of attempts.
+	// Otherwise, respond with an error result and acknowledge the event.
+	if allRetryable(result.errs) {
+		attempt := data.DeliveryAttempt
+		if h.redeliver(data) {
+			logger.Warn().Err(processErr).Int("gorch_delivery_attempt", attempt).Msg("Encountered a retryable error during the handling of the Request, requesting redelivery")
+			return processErr
 		}
-		return err
+
+		logger.Warn().Int("gorch_delivery_attempt", attempt).Time("gorch_publish_time", data.Message.PublishTime).Msg("Reached the maximum number of delivery attempts or age, giving up on retryable error")
+	}
+	if processErr != nil {
+		logger.Error().Err(processErr).Msg("Encountered an error during the handling of the Request")
+	}
+
+	return h.publish(ctx, req.ResponseTopic, req.Metadata, result)
+}
+
+// redeliver reports whether an event failing with a retryable error should be redelivered. Without a dead letter policy
+// the delivery attempt is always 0, so the age of the event bounds the number of redeliveries instead.
+func (h *Handler) redeliver(data CloudEventData) bool {
+	if data.DeliveryAttempt > 0 {
+		return data.DeliveryAttempt < h.maxAttempts
+	}
+
+	publishTime := data.Message.PublishTime
+	return publishTime.IsZero() || time.Since(publishTime) < h.maxAge
+}
+
+// publish sends the result of a request as a Response to its response topic.
+func (h *Handler) publish(ctx context.Context, responseTopic string, metadata RequestMetadata, result *Result) error {
+	logger := logging.Ctx(ctx)
+
+	if h.client == nil {
+		logger.Warn().Msg("Pubsub client is set to null, no responses will be sent")
+		return nil
+	}
+	if responseTopic == "" {
+		logger.Error().Msg("Request has no response topic, no response will be sent")
+		return nil
+	}
+
+	publisher := h.publishers.acquire(responseTopic)
+	defer h.publishers.release(publisher)
+
+	var res = Response{
+		APIVersion: APIVersionOrchestratorResponseV1,
+		Metadata:   metadata,
+		ResultCode: result.Code(),
+		Output:     base64.StdEncoding.EncodeToString([]byte(result.Output())),
+	}
+
+	var orderingKey string
+	if h.ordering {
+		orderingKey = metadata.ContextID
+	}
+
+	err := h.respond(ctx, publisher.publisher, &res, orderingKey)
+	if err != nil {
+		logger.Error().Err(err).Msg("Encountered an error when publishing the Response")
+	}
+
+	return err
+}
+
+// Close stops the handler from accepting new events, and waits for the events currently being handled to finish.
+// All response publishers are then flushed and stopped, and the Pub/Sub client is closed if it was created by the handler.
+// If ctx expires before all events have finished, the publishers are left running and ctx's error is returned.
+func (h *Handler) Close(ctx context.Context) error {
+	h.mu.Lock()
+	h.closed = true
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("unable to wait for in-flight events: %w", ctx.Err())
+	}
+
+	h.publishers.stop()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.ownsClient {
+		h.ownsClient = false
+		return h.client.Close()
 	}
+	return nil
 }
@@ -0,0 +1,460 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	cloudevent "github.com/cloudevents/sdk-go/v2/event"
+	"github.com/entur/go-orchestrator/oresources"
+	"github.com/rs/zerolog"
+)
+
+// -----------------------
+// Test Helpers
+// -----------------------
+
+type testHandler struct {
+	action func(context.Context, Request, *Result) error
+}
+
+func (h *testHandler) APIVersion() APIVersion { return "orchestrator.entur.io/test/v1" }
+func (h *testHandler) Kind() Kind             { return "Test" }
+
+func (h *testHandler) run(ctx context.Context, req Request, r *Result) error {
+	if h.action != nil {
+		return h.action(ctx, req, r)
+	}
+	r.Create("A thing")
+	r.Succeed("Did all the things")
+	return nil
+}
+
+func (h *testHandler) Plan(ctx context.Context, req Request, r *Result) error {
+	return h.run(ctx, req, r)
+}
+
+func (h *testHandler) PlanDestroy(ctx context.Context, req Request, r *Result) error {
+	return h.run(ctx, req, r)
+}
+
+func (h *testHandler) Apply(ctx context.Context, req Request, r *Result) error {
+	return h.run(ctx, req, r)
+}
+
+func (h *testHandler) Destroy(ctx context.Context, req Request, r *Result) error {
+	return h.run(ctx, req, r)
+}
+
+type testSO struct {
+	handlers []ManifestHandler
+}
+
+func (so *testSO) Handlers() []ManifestHandler {
+	return so.handlers
+}
+
+func (so *testSO) MiddlewareBefore(_ context.Context, _ Request, _ *Result) error {
+	return nil
+}
+
+func withTestLogger() HandlerOption {
+	return WithCustomLogger(zerolog.Nop())
+}
+
+func newTestManifest(h ManifestHandler) ManifestHeader {
+	return ManifestHeader{APIVersion: h.APIVersion(), Kind: h.Kind()}
+}
+
+// newTestCloudEvent wraps the request in the given envelope, and returns it as a CloudEvent.
+func newTestCloudEvent(t *testing.T, req *Request, envelope CloudEventData) cloudevent.Event {
+	t.Helper()
+
+	reqdata, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envelope.Message.Data = reqdata
+	data, err := json.Marshal(&envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := cloudevent.New(cloudevent.CloudEventsVersionV03)
+	e.DataEncoded = data
+	return e
+}
+
+// -----------------------
+// Tests
+// -----------------------
+
+func TestPubSubMessagePublishTime(t *testing.T) {
+	tests := []struct {
+		title string
+		data  string
+		want  time.Time
+	}{
+		{
+			title: "RFC3339",
+			data:  `{"messageId": "123", "publishTime": "2026-01-02T03:04:05.123Z"}`,
+			want:  time.Date(2026, 1, 2, 3, 4, 5, 123000000, time.UTC),
+		},
+		{
+			title: "Empty",
+			data:  `{"messageId": "123", "publishTime": ""}`,
+		},
+		{
+			title: "Missing",
+			data:  `{"messageId": "123"}`,
+		},
+		{
+			title: "Null",
+			data:  `{"messageId": "123", "publishTime": null}`,
+		},
+		{
+			title: "Malformed",
+			data:  `{"messageId": "123", "publishTime": "yesterday"}`,
+		},
+	}
+
+	for _, test := range tests {
+		tmp := test
+		t.Run(tmp.title, func(t *testing.T) {
+			t.Parallel()
+
+			var msg PubSubMessage
+			err := json.Unmarshal([]byte(tmp.data), &msg)
+			if err != nil {
+				t.Fatalf("unable to unmarshal message: %s", err)
+			}
+			if msg.ID != "123" {
+				t.Errorf("message id does not match expected value\ngot: %s\nwant: %s", msg.ID, "123")
+			}
+			if !msg.PublishTime.Equal(tmp.want) {
+				t.Errorf("publish time does not match expected value\ngot: %s\nwant: %s", msg.PublishTime, tmp.want)
+			}
+		})
+	}
+}
+
+func TestEnvelopeCtx(t *testing.T) {
+	publishTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	expected := CloudEventData{
+		Subscription: "projects/mock/subscriptions/mocksub",
+		Message: PubSubMessage{
+			ID:          "123",
+			PublishTime: publishTime,
+			Attributes: PubSubMessageAttributes{
+				"key": "value",
+			},
+			OrderingKey: "mockid",
+		},
+		DeliveryAttempt: 5,
+	}
+
+	var got CloudEventData
+	var found bool
+	mh := &testHandler{
+		action: func(ctx context.Context, _ Request, r *Result) error {
+			got, found = EnvelopeCtx(ctx)
+			r.Succeed("")
+			return nil
+		},
+	}
+	so := &testSO{handlers: []ManifestHandler{mh}}
+
+	req, err := NewMockRequest(newTestManifest(mh))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewCloudEventHandler(so, withTestLogger(), WithCustomPubSubClient(nil))
+	err = handler(context.Background(), newTestCloudEvent(t, req, expected))
+	if err != nil {
+		t.Fatalf("cloud event handler returned non-nil error\ngot: %s", err)
+	}
+
+	if !found {
+		t.Fatalf("envelope was not attached to the handler context")
+	}
+	if got.Subscription != expected.Subscription {
+		t.Errorf("envelope subscription does not match expected value\ngot: %s\nwant: %s", got.Subscription, expected.Subscription)
+	}
+	if got.Message.ID != expected.Message.ID {
+		t.Errorf("envelope message id does not match expected value\ngot: %s\nwant: %s", got.Message.ID, expected.Message.ID)
+	}
+	if !got.Message.PublishTime.Equal(publishTime) {
+		t.Errorf("envelope publish time does not match expected value\ngot: %s\nwant: %s", got.Message.PublishTime, publishTime)
+	}
+	if got.Message.Attributes["key"] != "value" {
+		t.Errorf("envelope attributes do not match expected value\ngot: %v\nwant: %v", got.Message.Attributes, expected.Message.Attributes)
+	}
+	if got.Message.OrderingKey != expected.Message.OrderingKey {
+		t.Errorf("envelope ordering key does not match expected value\ngot: %s\nwant: %s", got.Message.OrderingKey, expected.Message.OrderingKey)
+	}
+	if got.DeliveryAttempt != expected.DeliveryAttempt {
+		t.Errorf("envelope delivery attempt does not match expected value\ngot: %d\nwant: %d", got.DeliveryAttempt, expected.DeliveryAttempt)
+	}
+
+	// Processing requests directly should not have an envelope
+	result := Process(context.Background(), &testSO{handlers: []ManifestHandler{mh}}, req)
+	if result.Code() != ResultCodeNoop {
+		t.Fatalf("result code does not match expected value\ngot: %s\nwant: %s", result.Code(), ResultCodeNoop)
+	}
+	if found {
+		t.Errorf("envelope was unexpectedly attached to the process context")
+	}
+}
+
+func TestIAMLookupCtx(t *testing.T) {
+	var clients []*oresources.IAMLookupClient
+	mh := &testHandler{
+		action: func(ctx context.Context, _ Request, r *Result) error {
+			client, _ := oresources.IAMLookup(ctx)
+			clients = append(clients, client)
+			r.Succeed("")
+			return nil
+		},
+	}
+	so := &testSO{handlers: []ManifestHandler{mh}}
+
+	req, err := NewMockRequest(newTestManifest(mh), WithIAMEndpoint("http://localhost:18001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	noURLReq, err := NewMockRequest(newTestManifest(mh))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clients are created once per URL
+	handler := NewCloudEventHandler(so, withTestLogger(), WithCustomPubSubClient(nil), WithIAMLookupAuth(oresources.AuthNone()))
+	for _, r := range []*Request{req, req, noURLReq} {
+		err = handler(context.Background(), newTestCloudEvent(t, r, CloudEventData{}))
+		if err != nil {
+			t.Fatalf("cloud event handler returned non-nil error\ngot: %s", err)
+		}
+	}
+	if clients[0] == nil || clients[0] != clients[1] {
+		t.Errorf("iam lookup client was not reused for the same url\ngot: %p, %p", clients[0], clients[1])
+	}
+	if clients[2] != nil {
+		t.Errorf("iam lookup client was attached to a request without an url")
+	}
+
+	// Clients can be overridden
+	override, err := oresources.NewIAMLookupClientWithAuth(context.Background(), "http://localhost:8002", oresources.AuthNone())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler = NewCloudEventHandler(so, withTestLogger(), WithCustomPubSubClient(nil), WithIAMLookupClient(override))
+	err = handler(context.Background(), newTestCloudEvent(t, noURLReq, CloudEventData{}))
+	if err != nil {
+		t.Fatalf("cloud event handler returned non-nil error\ngot: %s", err)
+	}
+	_ = Process(oresources.WithIAMLookup(context.Background(), override), so, req)
+	if clients[3] != override || clients[4] != override {
+		t.Errorf("iam lookup client was not overridden\ngot: %p, %p\nwant: %p", clients[3], clients[4], override)
+	}
+}
+
+func TestIAMLookupCtxWithoutCredentials(t *testing.T) {
+	// Hide any application default credentials
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+
+	var client *oresources.IAMLookupClient
+	mh := &testHandler{
+		action: func(ctx context.Context, _ Request, r *Result) error {
+			client, _ = oresources.IAMLookup(ctx)
+			r.Succeed("")
+			return nil
+		},
+	}
+	req, err := NewMockRequest(newTestManifest(mh), WithIAMEndpoint("http://localhost:18001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// By default, clients fall back to unauthenticated requests
+	result := Process(context.Background(), &testSO{handlers: []ManifestHandler{mh}}, req)
+	if result.Code() == ResultCodeError {
+		t.Fatalf("processing failed without credentials\ngot: %v", result.Errors())
+	}
+	if client == nil {
+		t.Errorf("iam lookup client was not attached without credentials")
+	}
+}
+
+type costsClient struct {
+	url string
+}
+
+type resourceSO struct {
+	testSO
+	created int
+}
+
+func (so *resourceSO) ResourceClients() map[string]oresources.ClientFactory {
+	return map[string]oresources.ClientFactory{
+		"costs": func(_ context.Context, url string) (any, error) {
+			so.created++
+			return &costsClient{url: url}, nil
+		},
+	}
+}
+
+func TestResourceClientsCtx(t *testing.T) {
+	var clients []*costsClient
+	mh := &testHandler{
+		action: func(ctx context.Context, _ Request, r *Result) error {
+			client, _ := oresources.Client[*costsClient](ctx, "costs")
+			clients = append(clients, client)
+			r.Succeed("")
+			return nil
+		},
+	}
+	so := &resourceSO{testSO: testSO{handlers: []ManifestHandler{mh}}}
+
+	req, err := NewMockRequest(newTestManifest(mh), WithResource("costs", "http://localhost:18002"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewCloudEventHandler(so, withTestLogger(), WithCustomPubSubClient(nil))
+	for range 2 {
+		err = handler(context.Background(), newTestCloudEvent(t, req, CloudEventData{}))
+		if err != nil {
+			t.Fatalf("cloud event handler returned non-nil error\ngot: %s", err)
+		}
+	}
+	if so.created != 1 || clients[0] == nil || clients[0] != clients[1] || clients[0].url != "http://localhost:18002" {
+		t.Errorf("costs client was not created once for the resource url\ngot: %d created, %v, %v", so.created, clients[0], clients[1])
+	}
+
+	// Clients can be overridden
+	override := &costsClient{url: "mock"}
+	handler = NewCloudEventHandler(so, withTestLogger(), WithCustomPubSubClient(nil), WithResourceClient("costs", override))
+	err = handler(context.Background(), newTestCloudEvent(t, req, CloudEventData{}))
+	if err != nil {
+		t.Fatalf("cloud event handler returned non-nil error\ngot: %s", err)
+	}
+	if clients[2] != override {
+		t.Errorf("costs client was not overridden\ngot: %v\nwant: %v", clients[2], override)
+	}
+}
+
+func TestResourceClientsLazy(t *testing.T) {
+	mh := &testHandler{
+		action: func(_ context.Context, _ Request, r *Result) error {
+			r.Succeed("")
+			return nil
+		},
+	}
+	so := &resourceSO{testSO: testSO{handlers: []ManifestHandler{mh}}}
+
+	req, err := NewMockRequest(newTestManifest(mh), WithResource("costs", "http://localhost:18003"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clients are only created once they are retrieved from the context
+	handler := NewCloudEventHandler(so, withTestLogger(), WithCustomPubSubClient(nil))
+	err = handler(context.Background(), newTestCloudEvent(t, req, CloudEventData{}))
+	if err != nil {
+		t.Fatalf("cloud event handler returned non-nil error\ngot: %s", err)
+	}
+	if so.created != 0 {
+		t.Errorf("costs client was created, even though the handler never used it")
+	}
+}
+
+func TestRetryableErrors(t *testing.T) {
+	type Test struct {
+		title       string
+		err         error
+		attempt     int
+		publishTime time.Time
+		expected    bool
+	}
+
+	var tests = []Test{
+		{
+			title:    "permanent error is acknowledged",
+			err:      fmt.Errorf("mock error"),
+			attempt:  1,
+			expected: false,
+		},
+		{
+			title:    "retryable error requests redelivery",
+			err:      Retryable(fmt.Errorf("mock error")),
+			attempt:  1,
+			expected: true,
+		},
+		{
+			title:    "retryable error with unknown delivery attempt requests redelivery",
+			err:      Retryable(fmt.Errorf("mock error")),
+			attempt:  0,
+			expected: true,
+		},
+		{
+			title:       "retryable error with unknown delivery attempt requests redelivery until the maximum age",
+			err:         Retryable(fmt.Errorf("mock error")),
+			attempt:     0,
+			publishTime: time.Now().Add(-time.Minute),
+			expected:    true,
+		},
+		{
+			title:       "retryable error with unknown delivery attempt is acknowledged after the maximum age",
+			err:         Retryable(fmt.Errorf("mock error")),
+			attempt:     0,
+			publishTime: time.Now().Add(-time.Hour),
+			expected:    false,
+		},
+		{
+			title:    "retryable error is acknowledged after the maximum number of attempts",
+			err:      Retryable(fmt.Errorf("mock error")),
+			attempt:  3,
+			expected: false,
+		},
+		{
+			title:    "wrapped retryable error requests redelivery",
+			err:      fmt.Errorf("wrapped: %w", Retryable(fmt.Errorf("mock error"))),
+			attempt:  2,
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		tmp := test
+		t.Run(tmp.title, func(t *testing.T) {
+			t.Parallel()
+
+			mh := &testHandler{
+				action: func(context.Context, Request, *Result) error {
+					return tmp.err
+				},
+			}
+			so := &testSO{handlers: []ManifestHandler{mh}}
+			handler := NewCloudEventHandler(so, withTestLogger(), WithCustomPubSubClient(nil), WithMaxDeliveryAttempts(3), WithMaxRedeliveryAge(10*time.Minute))
+
+			req, err := NewMockRequest(newTestManifest(mh))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = handler(context.Background(), newTestCloudEvent(t, req, CloudEventData{DeliveryAttempt: tmp.attempt, Message: PubSubMessage{PublishTime: tmp.publishTime}}))
+			if (err != nil) != tmp.expected {
+				t.Fatalf("cloud event handler redelivery request does not match expected value\ngot: %t (%v)\nwant: %t", err != nil, err, tmp.expected)
+			}
+			if tmp.expected && !IsRetryable(err) {
+				t.Errorf("cloud event handler returned a non-retryable error\ngot: %s", err)
+			}
+		})
+	}
+}
@@ -3,11 +3,15 @@ package orchestrator
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"runtime/debug"
 	"strings"
+	"time"
 
-	"cloud.google.com/go/pubsub/v2"
 	"github.com/entur/go-logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
 // -----------------------
@@ -16,6 +20,23 @@ import (
 
 type ctxKey struct{}
 
+// ErrPanic is wrapped by all errors caused by a recovered panic in a middleware or manifest handler.
+var ErrPanic = errors.New("recovered from panic")
+
+// stage runs a single step of the processing pipeline in its own span, and converts any panics into errors.
+func stage(ctx context.Context, name string, attrs []attribute.KeyValue, mattrs metric.MeasurementOption, fn func(context.Context) error) (err error) {
+	ctx, span := startSpan(ctx, name, attrs...)
+	defer func() {
+		if r := recover(); r != nil {
+			instrumentsFromCtx(ctx).panics.Add(ctx, 1, mattrs)
+			err = fmt.Errorf("%w: %v\n%s", ErrPanic, r, debug.Stack())
+		}
+		endSpan(span, err)
+	}()
+
+	return fn(ctx)
+}
+
 func process(ctx context.Context, so Orchestrator, h ManifestHandler, req *Request, res *Result) error {
 	var err error
 
@@ -28,10 +49,15 @@ func process(ctx context.Context, so Orchestrator, h ManifestHandler, req *Reque
 	kind := h.Kind()
 	action := req.Action
 
+	attrs := append(requestAttributes(req), AttributeAPIVersion.String(string(version)), AttributeKind.String(string(kind)))
+	mattrs := metricAttributes(action, version, kind)
+
 	before, ok := so.(MiddlewareBefore)
 	if ok {
 		logger.Debug().Msgf("Executing Orchestrator MiddlewareBefore")
-		err = before.MiddlewareBefore(ctx, *req, res)
+		err = stage(ctx, SpanNameOrchestratorBefore, attrs, mattrs, func(ctx context.Context) error {
+			return before.MiddlewareBefore(ctx, *req, res)
+		})
 		if err != nil {
 			return fmt.Errorf("orchestrator middleware (before): %w", err)
 		}
@@ -40,7 +66,9 @@ func process(ctx context.Context, so Orchestrator, h ManifestHandler, req *Reque
 	before, ok = h.(MiddlewareBefore)
 	if ok {
 		logger.Debug().Msgf("Executing ManifestHandler MiddlewareBefore (%s, %s, %s)", version, kind, action)
-		err = before.MiddlewareBefore(ctx, *req, res)
+		err = stage(ctx, SpanNameManifestHandlerBefore, attrs, mattrs, func(ctx context.Context) error {
+			return before.MiddlewareBefore(ctx, *req, res)
+		})
 		if err != nil {
 			return fmt.Errorf("manifesthandler middleware (before): %w", err)
 		}
@@ -48,19 +76,20 @@ func process(ctx context.Context, so Orchestrator, h ManifestHandler, req *Reque
 
 	if !res.locked {
 		logger.Debug().Msgf("Executing ManifestHandler (%s, %s, %s)", version, kind, action)
-		switch req.Action {
-		case ActionApply:
-			err = h.Apply(ctx, *req, res)
-		case ActionPlan:
-			err = h.Plan(ctx, *req, res)
-		case ActionPlanDestroy:
-			err = h.PlanDestroy(ctx, *req, res)
-		case ActionDestroy:
-			err = h.Destroy(ctx, *req, res)
-		default:
-			err = fmt.Errorf("invalid action")
-		}
-
+		err = stage(ctx, SpanNameManifestHandler, attrs, mattrs, func(ctx context.Context) error {
+			switch req.Action {
+			case ActionApply:
+				return h.Apply(ctx, *req, res)
+			case ActionPlan:
+				return h.Plan(ctx, *req, res)
+			case ActionPlanDestroy:
+				return h.PlanDestroy(ctx, *req, res)
+			case ActionDestroy:
+				return h.Destroy(ctx, *req, res)
+			default:
+				return fmt.Errorf("invalid action")
+			}
+		})
 		if err != nil {
 			return fmt.Errorf("manifesthandler (%s, %s, %s): %w", version, kind, action, err)
 		}
@@ -71,7 +100,9 @@ func process(ctx context.Context, so Orchestrator, h ManifestHandler, req *Reque
 	after, ok := h.(MiddlewareAfter)
 	if ok {
 		logger.Debug().Msgf("Executing ManifestHandler MiddlewareAfter (%s, %s, %s)", version, kind, action)
-		err = after.MiddlewareAfter(ctx, *req, res)
+		err = stage(ctx, SpanNameManifestHandlerAfter, attrs, mattrs, func(ctx context.Context) error {
+			return after.MiddlewareAfter(ctx, *req, res)
+		})
 		if err != nil {
 			return fmt.Errorf("manifesthandler middleware (after): %w", err)
 		}
@@ -80,7 +111,9 @@ func process(ctx context.Context, so Orchestrator, h ManifestHandler, req *Reque
 	after, ok = so.(MiddlewareAfter)
 	if ok {
 		logger.Debug().Msgf("Executing Orchestrator MiddlewareAfter")
-		err = after.MiddlewareAfter(ctx, *req, res)
+		err = stage(ctx, SpanNameOrchestratorAfter, attrs, mattrs, func(ctx context.Context) error {
+			return after.MiddlewareAfter(ctx, *req, res)
+		})
 		if err != nil {
 			return fmt.Errorf("orchestrator middleware (after): %w", err)
 		}
@@ -93,55 +126,53 @@ func process(ctx context.Context, so Orchestrator, h ManifestHandler, req *Reque
 	return nil
 }
 
-func respond(ctx context.Context, publisher *pubsub.Publisher, res *Response) error {
-	logger := logging.Ctx(ctx)
-	logger.Debug().Interface("gorch_response", res).Msg("Sending response")
+// -----------------------
+// Core
+// -----------------------
 
-	enc, err := json.Marshal(res)
+// Process validates the request (see ValidateRequest), and runs the middlewares and the manifest handler matching its
+// manifest. Invalid requests are not processed, and an error result is returned instead.
+func Process(ctx context.Context, so Orchestrator, req *Request) *Result {
+	err := ValidateRequest(req)
 	if err != nil {
-		return err
+		logging.Ctx(ctx).Error().Err(err).Msg("Encountered an invalid Request")
+		return &Result{errs: []error{err}}
 	}
 
-	publishResult := publisher.Publish(ctx, &pubsub.Message{
-		Data: enc,
-	})
-	_, err = publishResult.Get(ctx)
-	return err
+	resources := &resourceClients{iamLookupPool: defaultIAMLookupPool, pool: defaultClientPool}
+	return processRequest(resources.attach(ctx, so, req), so, newHandlerRegistry(so.Handlers()), req)
 }
 
-// -----------------------
-// Core
-// -----------------------
-
-func Process(ctx context.Context, so Orchestrator, req *Request) *Result {
+func processRequest(ctx context.Context, so Orchestrator, registry *handlerRegistry, req *Request) *Result {
 	logger := logging.Ctx(ctx)
 	logger.Debug().Interface("gorch_request", req).Msg("Processing request")
 
+	ctx, span := startSpan(ctx, SpanNameProcess, requestAttributes(req)...)
+	instr := instrumentsFromCtx(ctx)
+	start := time.Now()
+
 	var header ManifestHeader
 	result := &Result{}
 
+	// Only matched manifest headers are recorded as metric attributes, see MetricValueUnknown
+	version, kind := APIVersion(MetricValueUnknown), Kind(MetricValueUnknown)
+
 	err := json.Unmarshal(req.Manifest.New, &header)
 	if err != nil {
-		err = fmt.Errorf("unable to unmarshal ManifestHeader: %w", err)
+		err = fmt.Errorf("unable to unmarshal ManifestHeader: %w", req.AnnotateError(err))
 	} else {
-		handlers := so.Handlers()
-
-		// Loop through all manifest handlers in the so, and run the first one with a matching APIVersion and Kind.
-		match := false
-		for _, handler := range handlers {
-			if header.APIVersion == handler.APIVersion() && header.Kind == handler.Kind() {
-				logger.Debug().Msgf("Found ManifestHandler (%s, %s)", header.APIVersion, header.Kind)
-				err = process(ctx, so, handler, req, result)
-				match = true
-				break
-			}
-		}
-
-		// If we couldn't find a match, mark the result as having failed, and provide the user with a list of possible valid alternatives
-		if !match {
+		// Run the manifest handler with a matching APIVersion and Kind.
+		handler, match := registry.lookup(ManifestHeader{APIVersion: header.APIVersion, Kind: header.Kind})
+		if match {
+			logger.Debug().Msgf("Found ManifestHandler (%s, %s)", header.APIVersion, header.Kind)
+			version, kind = header.APIVersion, header.Kind
+			err = process(ctx, so, handler, req, result)
+		} else {
+			// If we couldn't find a match, mark the result as having failed, and provide the user with a list of possible valid alternatives
 			logger.Debug().Msgf("Could not find ManifestHandler (%s, %s)", header.APIVersion, header.Kind)
-			suggestions := make([]string, 0, len(handlers))
-			for _, handler := range handlers {
+			instr.unmatched.Add(ctx, 1, metric.WithAttributes(AttributeAction.String(string(req.Action))))
+			suggestions := make([]string, 0, len(registry.handlers))
+			for _, handler := range registry.handlers {
 				suggestion := fmt.Sprintf("apiVersion: %s\nkind: %s", handler.APIVersion(), handler.Kind())
 				suggestions = append(suggestions, suggestion)
 			}
@@ -155,6 +186,18 @@ func Process(ctx context.Context, so Orchestrator, req *Request) *Result {
 		result.errs = append(result.errs, err)
 	}
 
+	code := AttributeResultCode.String(string(result.Code()))
+	mattrs := metricAttributes(req.Action, version, kind, code)
+	instr.requests.Add(ctx, 1, mattrs)
+	instr.handlerDuration.Record(ctx, seconds(start), mattrs)
+
+	span.SetAttributes(
+		AttributeAPIVersion.String(string(header.APIVersion)),
+		AttributeKind.String(string(header.Kind)),
+		code,
+	)
+	endSpan(span, err)
+
 	return result
 }
 
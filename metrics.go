@@ -0,0 +1,133 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// -----------------------
+// Metrics
+// -----------------------
+
+const meterName = tracerName
+
+// Value of the apiVersion and kind metric attributes of requests without a matching ManifestHandler. These come straight
+// from user supplied manifests, so recording them as is would create an unbounded number of series.
+const MetricValueUnknown = "unknown"
+
+// Metric names recorded by the CloudEvent handler and Process.
+const (
+	MetricNameRequests        = "gorch.requests"         // Number of processed requests, by action, apiVersion, kind and result code
+	MetricNameHandlerDuration = "gorch.handler.duration" // Duration of processing a request, including middlewares, by action, apiVersion, kind and result code
+	MetricNamePublishDuration = "gorch.publish.duration" // Duration of publishing a response, by response topic and result code
+	MetricNameUnmatched       = "gorch.unmatched"        // Number of requests without a matching ManifestHandler, by action
+	MetricNamePanics          = "gorch.panics"           // Number of recovered panics, by action, apiVersion and kind
+)
+
+type instruments struct {
+	requests        metric.Int64Counter
+	handlerDuration metric.Float64Histogram
+	publishDuration metric.Float64Histogram
+	unmatched       metric.Int64Counter
+	panics          metric.Int64Counter
+}
+
+type instrumentsCtxKey struct{}
+
+// newInstruments creates all instruments using the given provider.
+// The returned instruments are always usable, even if an error is returned.
+func newInstruments(mp metric.MeterProvider) (*instruments, error) {
+	var err, errs error
+	meter := mp.Meter(meterName)
+	i := &instruments{}
+
+	i.requests, err = meter.Int64Counter(MetricNameRequests,
+		metric.WithDescription("Number of processed requests"),
+		metric.WithUnit("{request}"),
+	)
+	errs = errors.Join(errs, err)
+
+	i.handlerDuration, err = meter.Float64Histogram(MetricNameHandlerDuration,
+		metric.WithDescription("Duration of processing a request, including middlewares"),
+		metric.WithUnit("s"),
+	)
+	errs = errors.Join(errs, err)
+
+	i.publishDuration, err = meter.Float64Histogram(MetricNamePublishDuration,
+		metric.WithDescription("Duration of publishing a response"),
+		metric.WithUnit("s"),
+	)
+	errs = errors.Join(errs, err)
+
+	i.unmatched, err = meter.Int64Counter(MetricNameUnmatched,
+		metric.WithDescription("Number of requests without a matching ManifestHandler"),
+		metric.WithUnit("{request}"),
+	)
+	errs = errors.Join(errs, err)
+
+	i.panics, err = meter.Int64Counter(MetricNamePanics,
+		metric.WithDescription("Number of recovered panics"),
+		metric.WithUnit("{panic}"),
+	)
+	errs = errors.Join(errs, err)
+
+	if errs != nil {
+		return i, fmt.Errorf("unable to create metric instruments: %w", errs)
+	}
+	return i, nil
+}
+
+// The global MeterProvider delegates to whichever provider is registered later on,
+// so the global instruments only ever need to be created once.
+var globalInstruments = sync.OnceValue(func() *instruments {
+	i, _ := newInstruments(otel.GetMeterProvider())
+	return i
+})
+
+// instrumentsFromCtx returns the instruments attached by the CloudEvent handler,
+// or instruments created from the globally registered provider if there are none.
+func instrumentsFromCtx(ctx context.Context) *instruments {
+	i, ok := ctx.Value(instrumentsCtxKey{}).(*instruments)
+	if !ok {
+		return globalInstruments()
+	}
+	return i
+}
+
+func seconds(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+func metricAttributes(action Action, version APIVersion, kind Kind, attrs ...attribute.KeyValue) metric.MeasurementOption {
+	return metric.WithAttributes(append([]attribute.KeyValue{
+		AttributeAction.String(string(action)),
+		AttributeAPIVersion.String(string(version)),
+		AttributeKind.String(string(kind)),
+	}, attrs...)...)
+}
+
+// NewPrometheusMeterProvider returns a MeterProvider which exposes all recorded metrics in the Prometheus format
+// through the returned http.Handler. It can be used along with WithMeterProvider.
+func NewPrometheusMeterProvider() (*sdkmetric.MeterProvider, http.Handler, error) {
+	registry := prometheus.NewRegistry()
+
+	exporter, err := otelprom.New(otelprom.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create prometheus exporter: %w", err)
+	}
+
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(exporter))
+	return mp, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), nil
+}
@@ -0,0 +1,147 @@
+package orchestrator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// sumDataPoint returns the value of the counter data point with the given attribute, or -1 if there is none.
+func sumDataPoint(t *testing.T, rm metricdata.ResourceMetrics, name string, key attribute.Key, value string) int64 {
+	t.Helper()
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				t.Fatalf("metric '%s' is not an int64 sum", name)
+			}
+
+			for _, dp := range sum.DataPoints {
+				v, ok := dp.Attributes.Value(key)
+				if ok && v.Emit() == value {
+					return dp.Value
+				}
+			}
+		}
+	}
+
+	return -1
+}
+
+func TestMetrics(t *testing.T) {
+	type Expected struct {
+		code      ResultCode
+		kind      string
+		requests  int64
+		unmatched int64
+		panics    int64
+	}
+
+	type Test struct {
+		title    string
+		manifest any
+		action   func(context.Context, Request, *Result) error
+		expected Expected
+	}
+
+	mh := &testHandler{}
+
+	var tests = []Test{
+		{
+			title:    "successful request",
+			manifest: newTestManifest(mh),
+			expected: Expected{code: ResultCodeSuccess, kind: string(mh.Kind()), requests: 1, unmatched: -1, panics: -1},
+		},
+		{
+			title:    "unmatched kind",
+			manifest: ManifestHeader{APIVersion: mh.APIVersion(), Kind: "MockRandomKind"},
+			expected: Expected{code: ResultCodeFailure, kind: MetricValueUnknown, requests: 1, unmatched: 1, panics: -1},
+		},
+		{
+			title:    "panicking manifest handler",
+			manifest: newTestManifest(mh),
+			action: func(context.Context, Request, *Result) error {
+				panic("mock panic")
+			},
+			expected: Expected{code: ResultCodeError, kind: string(mh.Kind()), requests: 1, unmatched: -1, panics: 1},
+		},
+	}
+
+	for _, test := range tests {
+		tmp := test
+		t.Run(tmp.title, func(t *testing.T) {
+			t.Parallel()
+
+			reader := sdkmetric.NewManualReader()
+			mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+			so := &testSO{handlers: []ManifestHandler{&testHandler{action: tmp.action}}}
+			handler := NewCloudEventHandler(so, withTestLogger(), WithCustomPubSubClient(nil), WithMeterProvider(mp))
+
+			e, err := NewMockCloudEvent(tmp.manifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = handler(context.Background(), *e)
+
+			var rm metricdata.ResourceMetrics
+			err = reader.Collect(context.Background(), &rm)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			requests := sumDataPoint(t, rm, MetricNameRequests, AttributeResultCode, string(tmp.expected.code))
+			if requests != tmp.expected.requests {
+				t.Errorf("number of '%s' requests does not match expected value\ngot: %d\nwant: %d", tmp.expected.code, requests, tmp.expected.requests)
+			}
+			requests = sumDataPoint(t, rm, MetricNameRequests, AttributeKind, tmp.expected.kind)
+			if requests != tmp.expected.requests {
+				t.Errorf("number of '%s' kind requests does not match expected value\ngot: %d\nwant: %d", tmp.expected.kind, requests, tmp.expected.requests)
+			}
+			unmatched := sumDataPoint(t, rm, MetricNameUnmatched, AttributeAction, string(DefaultMockAction))
+			if unmatched != tmp.expected.unmatched {
+				t.Errorf("number of unmatched requests does not match expected value\ngot: %d\nwant: %d", unmatched, tmp.expected.unmatched)
+			}
+			panics := sumDataPoint(t, rm, MetricNamePanics, AttributeAction, string(DefaultMockAction))
+			if panics != tmp.expected.panics {
+				t.Errorf("number of panics does not match expected value\ngot: %d\nwant: %d", panics, tmp.expected.panics)
+			}
+		})
+	}
+}
+
+func TestPrometheusMeterProvider(t *testing.T) {
+	mp, promHandler, err := NewPrometheusMeterProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mh := &testHandler{}
+	so := &testSO{handlers: []ManifestHandler{mh}}
+	handler := NewCloudEventHandler(so, withTestLogger(), WithCustomPubSubClient(nil), WithMeterProvider(mp))
+
+	e, err := NewMockCloudEvent(newTestManifest(mh))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = handler(context.Background(), *e)
+
+	rec := httptest.NewRecorder()
+	promHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "gorch_requests_total") {
+		t.Errorf("prometheus output does not contain the requests counter\ngot: %s", body)
+	}
+}
@@ -3,6 +3,8 @@ package orchestrator
 import (
 	"encoding/base64"
 	"encoding/json"
+	"strings"
+	"time"
 
 	cloudevent "github.com/cloudevents/sdk-go/v2/event"
 )
@@ -20,6 +22,8 @@ const DefaultMockUsername = "mockuser"                            // Default Git
 const DefaultMockUserEmail = "mockuser@entur.io"                  // Default verified user email used in PO request mocks.
 const DefaultMockUserPermission = RepositoryPermissionAdmin       // Default Repository permissions used in PO request mocks.
 const DefaultMockAction = ActionPlan                              // Default User action used in PO request mocks.
+const DefaultMockMessageID = "id"                                 // Default Pub/Sub message ID used in CloudEvent mocks.
+const DefaultMockSubscription = "sub"                             // Default Pub/Sub subscription used in CloudEvent mocks.
 
 type MockRequestOption func(*Request)
 
@@ -47,6 +51,33 @@ func WithIAMEndpoint(url string) MockRequestOption {
 	}
 }
 
+func WithResource(name string, url string) MockRequestOption {
+	return func(req *Request) {
+		req.Resources.Set(name, Resource{URL: url})
+	}
+}
+
+func WithResponseTopic(topic string) MockRequestOption {
+	return func(req *Request) {
+		req.ResponseTopic = topic
+	}
+}
+
+// WithRepositoryFullName sets the full name ('owner/name') and name of the origin repository.
+func WithRepositoryFullName(fullName string) MockRequestOption {
+	return func(req *Request) {
+		req.Origin.Repository.FullName = fullName
+		req.Origin.Repository.Name = fullName[strings.LastIndex(fullName, "/")+1:]
+	}
+}
+
+// WithOldManifest sets the old manifest, as sent when an existing manifest is changed or removed.
+func WithOldManifest(manifest Manifest) MockRequestOption {
+	return func(req *Request) {
+		req.Manifest.Old = &manifest
+	}
+}
+
 func NewMockRequest(manifest any, opts ...MockRequestOption) (*Request, error) {
 	newManifest, err := json.Marshal(manifest)
 	if err != nil {
@@ -54,7 +85,7 @@ func NewMockRequest(manifest any, opts ...MockRequestOption) (*Request, error) {
 	}
 
 	req := &Request{
-		APIVersion: "orchestrator.entur.io/request/v1",
+		APIVersion: APIVersionOrchestratorRequestV1,
 		Metadata: RequestMetadata{
 			RequestID: DefaultMockRequestID,
 			ContextID: DefaultMockContextID,
@@ -91,6 +122,23 @@ func NewMockRequest(manifest any, opts ...MockRequestOption) (*Request, error) {
 	return req, err
 }
 
+// NewMockRequestFromFile creates a mock request from a YAML or JSON manifest file.
+// The positions of the manifest fields are kept, so that Request.FieldError can point at the line and column of a field.
+func NewMockRequestFromFile(path string, opts ...MockRequestOption) (*Request, error) {
+	manifest, sources, err := ReadManifestFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := NewMockRequest(manifest, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	req.sources = sources
+	return req, nil
+}
+
 func NewMockCloudEvent(manifest any, opts ...MockRequestOption) (*cloudevent.Event, error) {
 	req, err := NewMockRequest(manifest, opts...)
 	if err != nil {
@@ -107,11 +155,12 @@ func NewMockCloudEvent(manifest any, opts ...MockRequestOption) (*cloudevent.Eve
 	data, err := json.Marshal(&CloudEventData{
 		Message: PubSubMessage{
 			Data:        reqdata,
-			ID:          "id",
-			PublishTime: "time",
+			ID:          DefaultMockMessageID,
+			PublishTime: time.Now().UTC(),
 			Attributes:  PubSubMessageAttributes{},
 		},
-		Subscription: "sub",
+		Subscription:    DefaultMockSubscription,
+		DeliveryAttempt: 1,
 	})
 	if err != nil {
 		return nil, err
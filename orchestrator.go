@@ -3,10 +3,13 @@ package orchestrator
 import (
 	"context"
 	"encoding/json"
+	"maps"
 	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/entur/go-logging"
+	"github.com/entur/go-orchestrator/oresources"
 )
 
 // -----------------------
@@ -22,10 +25,12 @@ type Request struct {
 	Origin        Origin          `json:"origin"`
 	Sender        Sender          `json:"sender"`
 	ResponseTopic string          `json:"responseTopic"`
+
+	sources SourceMap // Positions of the new manifest fields, if the request was created from a manifest file
 }
 
 type Response struct {
-	APIVersion APIVersion      `json:"apiVersion"` // 'orchestrator.entur.io/response/v1'
+	APIVersion APIVersion      `json:"apiVersion"` // 'orchestrator.entur.io/request/v1', see APIVersionOrchestratorResponseV1
 	Metadata   RequestMetadata `json:"metadata"`
 	ResultCode ResultCode      `json:"result"` // 'success'
 	Output     string          `json:"output"`
@@ -40,11 +45,28 @@ const (
 	ActionDestroy     Action = "destroy"
 )
 
+// Get all valid actions.
+func Actions() []Action {
+	return []Action{ActionApply, ActionPlan, ActionPlanDestroy, ActionDestroy}
+}
+
+// Is the action one of the valid actions.
+func (a Action) IsValid() bool {
+	switch a {
+	case ActionApply, ActionPlan, ActionPlanDestroy, ActionDestroy:
+		return true
+	default:
+		return false
+	}
+}
+
 type APIVersion string // Platform Orchestrator / Sub-Orchestrator APIVersion
 
 const (
-	APIVersionOrchestratorResponseV1 APIVersion = "orchestrator.entur.io/request/v1"  // Platform Orchestrator Request
-	APIVersionOrchestratorRequestV1  APIVersion = "orchestrator.entur.io/response/v1" // Platform Orchestrator Response
+	APIVersionOrchestratorRequestV1 APIVersion = "orchestrator.entur.io/request/v1" // Platform Orchestrator Request
+	// Platform Orchestrator Response. The Platform Orchestrator expects responses with the same apiVersion as requests,
+	// so this is not 'orchestrator.entur.io/response/v1'.
+	APIVersionOrchestratorResponseV1 APIVersion = "orchestrator.entur.io/request/v1"
 )
 
 type Manifest = json.RawMessage
@@ -74,8 +96,85 @@ type RequestMetadata struct {
 	ContextID string `json:"contextId"` // Context ID specified by Platform Orchestrator used to track the user request
 }
 
+// The Resources type holds the platform resources sent with a request, by name. Resources without a dedicated field
+// are available through Get.
 type Resources struct {
 	IAMLookup ResourceIAMLookup `json:"iamLookup"`
+
+	others map[string]Resource
+	raw    map[string]json.RawMessage // Entries which are not resources with a URL, kept as is
+}
+
+// Get returns the named resource, e.g. oresources.ResourceIAMLookup. The second return value is false if the request
+// has no resource with that name.
+func (r Resources) Get(name string) (Resource, bool) {
+	if name == oresources.ResourceIAMLookup {
+		return r.IAMLookup, r.IAMLookup.URL != ""
+	}
+	resource, ok := r.others[name]
+	return resource, ok
+}
+
+// Set adds or replaces the named resource.
+func (r *Resources) Set(name string, resource Resource) {
+	delete(r.raw, name)
+	if name == oresources.ResourceIAMLookup {
+		r.IAMLookup = resource
+		return
+	}
+	if r.others == nil {
+		r.others = map[string]Resource{}
+	}
+	r.others[name] = resource
+}
+
+// Names returns the names of all resources in the request, sorted.
+func (r Resources) Names() []string {
+	names := slices.Collect(maps.Keys(r.others))
+	if r.IAMLookup.URL != "" {
+		names = append(names, oresources.ResourceIAMLookup)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// UnmarshalJSON decodes every entry which is a resource with a URL. Other entries, which the Platform Orchestrator may
+// add in the future, are ignored by Get and Names, but kept as is when marshalling the resources again.
+func (r *Resources) UnmarshalJSON(data []byte) error {
+	var entries map[string]json.RawMessage
+	err := json.Unmarshal(data, &entries)
+	if err != nil {
+		return err
+	}
+
+	*r = Resources{}
+	for name, entry := range entries {
+		var resource Resource
+		err = json.Unmarshal(entry, &resource)
+		if err != nil || resource.URL == "" {
+			if r.raw == nil {
+				r.raw = map[string]json.RawMessage{}
+			}
+			r.raw[name] = entry
+			continue
+		}
+		r.Set(name, resource)
+	}
+	return nil
+}
+
+func (r Resources) MarshalJSON() ([]byte, error) {
+	entries := make(map[string]any, len(r.raw)+len(r.others)+1)
+	for name, entry := range r.raw {
+		entries[name] = entry
+	}
+	for name, resource := range r.others {
+		entries[name] = resource
+	}
+	if r.IAMLookup.URL != "" {
+		entries[oresources.ResourceIAMLookup] = r.IAMLookup
+	}
+	return json.Marshal(entries)
 }
 
 type ResourceIAMLookup = Resource
@@ -259,6 +358,13 @@ type MiddlewareAfter interface {
 	MiddlewareAfter(context.Context, Request, *Result) error
 }
 
+// The ResourceClients interface can optionally be implemented by a sub-orchestrator, to have a client created for each
+// named resource in req.Resources with a registered factory. Clients are created once per URL, and attached to the
+// request context, see oresources.Client.
+type ResourceClients interface {
+	ResourceClients() map[string]oresources.ClientFactory
+}
+
 // The ManifestHandler interface represents the logic used for handling a specific APIVersion and Kind.
 type ManifestHandler interface {
 	// Which APIVersion and Kind this handler operates on
@@ -3,6 +3,7 @@ package orchestrator_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/entur/go-logging"
@@ -105,9 +106,9 @@ func (so *ExampleSO) MiddlewareBefore(ctx context.Context, req orchestrator.Requ
 
 	if req.Sender.Type == orchestrator.SenderTypeUser {
 		logger.Info().Msg("#####")
-		client, err := oresources.NewIAMLookupClient(ctx, req.Resources.IAMLookup.URL)
-		if err != nil {
-			return err
+		client, ok := oresources.IAMLookup(ctx)
+		if !ok {
+			return errors.New("no iam lookup client attached to the request")
 		}
 
 		access, err := client.GCPUserHasRoleInProjects(ctx, req.Sender.Email, "your_so_role", "ent-someproject-dev")
@@ -191,6 +192,7 @@ func Example() {
 	handler := orchestrator.NewCloudEventHandler(so,
 		orchestrator.WithCustomLogger(logger),
 		orchestrator.WithCustomPubSubClient(nil),
+		orchestrator.WithIAMLookupAuth(oresources.AuthNone()),
 	)
 
 	manifest := ExampleManifestV1{
@@ -220,7 +222,6 @@ func Example() {
 	// DBG Executing Orchestrator MiddlewareBefore gorch_action=plan gorch_context_id=mockid gorch_file_name= gorch_github_user_id=0 gorch_request_id=mockid
 	// INF Before it begins gorch_action=plan gorch_context_id=mockid gorch_file_name= gorch_github_user_id=0 gorch_request_id=mockid
 	// INF ##### gorch_action=plan gorch_context_id=mockid gorch_file_name= gorch_github_user_id=0 gorch_request_id=mockid
-	// DBG Unable to discover idtoken credentials, defaulting to http.Client for IAMLookup gorch_action=plan gorch_context_id=mockid gorch_file_name= gorch_github_user_id=0 gorch_request_id=mockid
 	// DBG Executing ManifestHandler MiddlewareBefore (orchestrator.entur.io/example/v1, Example, plan) gorch_action=plan gorch_context_id=mockid gorch_file_name= gorch_github_user_id=0 gorch_request_id=mockid
 	// INF After Orchestrator middleware executes, but before manifest handler executes gorch_action=plan gorch_context_id=mockid gorch_file_name= gorch_github_user_id=0 gorch_request_id=mockid
 	// DBG Executing ManifestHandler (orchestrator.entur.io/example/v1, Example, plan) gorch_action=plan gorch_context_id=mockid gorch_file_name= gorch_github_user_id=0 gorch_request_id=mockid
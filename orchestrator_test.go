@@ -1,7 +1,11 @@
 package orchestrator
 
 import (
+	"encoding/json"
+	"slices"
 	"testing"
+
+	"github.com/entur/go-orchestrator/oresources"
 )
 
 func TestChangesFromUnknownValues(t *testing.T) {
@@ -113,3 +117,70 @@ func TestChangesFromUnknownValues(t *testing.T) {
 		})
 	}
 }
+
+func TestResources(t *testing.T) {
+	data := []byte(`{"iamLookup": {"url": "https://iam.entur.io"}, "costs": {"url": "https://costs.entur.io"}}`)
+
+	var resources Resources
+	err := json.Unmarshal(data, &resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resources.IAMLookup.URL != "https://iam.entur.io" {
+		t.Errorf("iam lookup url does not match expected value\ngot: %s\nwant: %s", resources.IAMLookup.URL, "https://iam.entur.io")
+	}
+	costs, ok := resources.Get("costs")
+	if !ok || costs.URL != "https://costs.entur.io" {
+		t.Errorf("costs resource does not match expected value\ngot: %v\nwant: %s", costs, "https://costs.entur.io")
+	}
+	if _, ok := resources.Get("unknown"); ok {
+		t.Errorf("unknown resource was found")
+	}
+	if names := resources.Names(); !slices.Equal(names, []string{"costs", "iamLookup"}) {
+		t.Errorf("resource names do not match expected value\ngot: %v\nwant: %v", names, []string{"costs", "iamLookup"})
+	}
+
+	enc, err := json.Marshal(resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"costs":{"url":"https://costs.entur.io"},"iamLookup":{"url":"https://iam.entur.io"}}`
+	if string(enc) != want {
+		t.Errorf("marshalled resources do not match expected value\ngot: %s\nwant: %s", enc, want)
+	}
+}
+
+func TestResourcesUnknownEntries(t *testing.T) {
+	data := []byte(`{"costs": {"url": "https://costs.entur.io"}, "flags": ["a", "b"], "limit": 5, "empty": {}}`)
+
+	var req Request
+	err := json.Unmarshal([]byte(`{"resources": `+string(data)+`}`), &req)
+	if err != nil {
+		t.Fatalf("request with unknown resource entries was rejected: %s", err)
+	}
+	if names := req.Resources.Names(); !slices.Equal(names, []string{"costs"}) {
+		t.Errorf("resource names do not match expected value\ngot: %v\nwant: %v", names, []string{"costs"})
+	}
+	if _, ok := req.Resources.Get(oresources.ResourceIAMLookup); ok {
+		t.Errorf("missing iam lookup resource was found")
+	}
+
+	// Unknown entries are kept as is, and the missing iam lookup resource is not added
+	enc, err := json.Marshal(req.Resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"costs":{"url":"https://costs.entur.io"},"empty":{},"flags":["a","b"],"limit":5}`
+	if string(enc) != want {
+		t.Errorf("marshalled resources do not match expected value\ngot: %s\nwant: %s", enc, want)
+	}
+
+	enc, err = json.Marshal(Resources{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(enc) != "{}" {
+		t.Errorf("marshalled empty resources do not match expected value\ngot: %s\nwant: %s", enc, "{}")
+	}
+}
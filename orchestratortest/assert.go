@@ -0,0 +1,178 @@
+package orchestratortest
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/entur/go-orchestrator"
+	"github.com/rs/zerolog"
+)
+
+// -----------------------
+// Assertions
+// -----------------------
+
+// The ResultAssertion type provides chainable assertions on a Result.
+// Every failed assertion is reported along with the full result, including all internal errors and their stack traces.
+type ResultAssertion struct {
+	t   testing.TB
+	res *orchestrator.Result
+}
+
+// AssertResult starts a chain of assertions on the result, e.g.
+//
+//	orchestratortest.AssertResult(t, res).Code(orchestrator.ResultCodeSuccess).Creates("Team platform").NoErrors()
+func AssertResult(t testing.TB, res *orchestrator.Result) *ResultAssertion {
+	t.Helper()
+	if res == nil {
+		t.Fatalf("result is nil")
+	}
+	return &ResultAssertion{t: t, res: res}
+}
+
+func (a *ResultAssertion) fail(format string, args ...any) {
+	a.t.Helper()
+	a.t.Errorf("%s\n\n%s", fmt.Sprintf(format, args...), FormatResult(a.res))
+}
+
+// Code asserts that the result has the given result code.
+func (a *ResultAssertion) Code(code orchestrator.ResultCode) *ResultAssertion {
+	a.t.Helper()
+	if a.res.Code() != code {
+		a.fail("result code does not match expected value\ngot: %s\nwant: %s", a.res.Code(), code)
+	}
+	return a
+}
+
+// Creates asserts that the result contains exactly the given 'create' changes, in order.
+func (a *ResultAssertion) Creates(changes ...string) *ResultAssertion {
+	a.t.Helper()
+	a.changes("create", a.res.Creations(), changes)
+	return a
+}
+
+// Updates asserts that the result contains exactly the given 'update' changes, in order.
+func (a *ResultAssertion) Updates(changes ...string) *ResultAssertion {
+	a.t.Helper()
+	a.changes("update", a.res.Updates(), changes)
+	return a
+}
+
+// Deletes asserts that the result contains exactly the given 'delete' changes, in order.
+func (a *ResultAssertion) Deletes(changes ...string) *ResultAssertion {
+	a.t.Helper()
+	a.changes("delete", a.res.Deletions(), changes)
+	return a
+}
+
+// NoChanges asserts that the result contains no changes at all.
+func (a *ResultAssertion) NoChanges() *ResultAssertion {
+	a.t.Helper()
+	return a.Creates().Updates().Deletes()
+}
+
+func (a *ResultAssertion) changes(kind string, got []orchestrator.Change, want []string) {
+	a.t.Helper()
+	values := changeStrings(got)
+	if !slices.Equal(values, want) {
+		a.fail("'%s' changes do not match expected value\ngot: %q\nwant: %q", kind, values, want)
+	}
+}
+
+// NoErrors asserts that no internal errors have accumulated in the result.
+func (a *ResultAssertion) NoErrors() *ResultAssertion {
+	a.t.Helper()
+	if len(a.res.Errors()) > 0 {
+		a.fail("result contains %d internal error(s)", len(a.res.Errors()))
+	}
+	return a
+}
+
+// ErrorContains asserts that at least one of the internal errors contains the given string.
+func (a *ResultAssertion) ErrorContains(str string) *ResultAssertion {
+	a.t.Helper()
+	for _, err := range a.res.Errors() {
+		if strings.Contains(err.Error(), str) {
+			return a
+		}
+	}
+	a.fail("result does not contain an internal error containing expected value\nwant: %s", str)
+	return a
+}
+
+// Output asserts that the result output is exactly the given string.
+func (a *ResultAssertion) Output(output string) *ResultAssertion {
+	a.t.Helper()
+	if a.res.Output() != output {
+		a.fail("result output does not match expected value\n%s", diff(output, a.res.Output()))
+	}
+	return a
+}
+
+// OutputContains asserts that the result output contains the given string.
+func (a *ResultAssertion) OutputContains(str string) *ResultAssertion {
+	a.t.Helper()
+	if !strings.Contains(a.res.Output(), str) {
+		a.fail("result output does not contain expected value\nwant: %s", str)
+	}
+	return a
+}
+
+// Locked asserts that the result has been marked as either succeeded or failed.
+func (a *ResultAssertion) Locked() *ResultAssertion {
+	a.t.Helper()
+	if !a.res.Locked() {
+		a.fail("result is not locked, .Succeed(msg) or .Fail(msg) was never called")
+	}
+	return a
+}
+
+// FormatResult renders the full result, including the stack traces of all internal errors, for use in test failures.
+func FormatResult(res *orchestrator.Result) string {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "Result:\n  code: %s\n  locked: %t\n  output:\n", res.Code(), res.Locked())
+	for _, line := range strings.Split(res.Output(), "\n") {
+		fmt.Fprintf(&builder, "    %s\n", line)
+	}
+
+	errs := res.Errors()
+	if len(errs) > 0 {
+		builder.WriteString("  errors:\n")
+		for i, err := range errs {
+			msg := err.Error()
+			if stack := formatStack(err); stack != "" {
+				msg += "\n" + stack
+			}
+			fmt.Fprintf(&builder, "  [%d] %s\n", i, strings.ReplaceAll(msg, "\n", "\n      "))
+		}
+	}
+
+	return builder.String()
+}
+
+// formatStack renders the stack trace recorded by errors such as logging.NewStackTraceError, using the same
+// zerolog.ErrorStackMarshaler which renders it in the logs. Errors without a stack trace are rendered as an empty string.
+func formatStack(err error) string {
+	if zerolog.ErrorStackMarshaler == nil {
+		return ""
+	}
+
+	switch stack := zerolog.ErrorStackMarshaler(err).(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(stack)
+	case []byte:
+		return strings.TrimSpace(string(stack))
+	default:
+		enc, err := json.MarshalIndent(stack, "", "  ")
+		if err != nil {
+			return fmt.Sprint(stack)
+		}
+		return string(enc)
+	}
+}
@@ -0,0 +1,96 @@
+package orchestratortest_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/entur/go-orchestrator"
+	"github.com/entur/go-orchestrator/orchestratortest"
+	"github.com/rs/zerolog"
+)
+
+// recordingTB records failures instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	failures []string
+}
+
+func (tb *recordingTB) Helper() {}
+
+func (tb *recordingTB) Errorf(format string, args ...any) {
+	tb.failures = append(tb.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertResult(t *testing.T) {
+	res := orchestrator.Process(context.Background(), &teamSO{}, newTeamRequest(t, "platform"))
+
+	// Passing assertions
+	orchestratortest.AssertResult(t, res).
+		Code(orchestrator.ResultCodeSuccess).
+		Locked().
+		Creates("Team platform").
+		Updates().
+		Deletes().
+		NoErrors().
+		OutputContains("+ Team platform")
+
+	// Failing assertions should all be reported, along with the full result
+	tb := &recordingTB{TB: t}
+	orchestratortest.AssertResult(tb, res).
+		Code(orchestrator.ResultCodeNoop).
+		Creates("Team other").
+		NoChanges().
+		ErrorContains("boom").
+		OutputContains("- Team platform")
+
+	if len(tb.failures) != 5 {
+		t.Fatalf("number of failures does not match expected value\ngot: %d\nwant: %d\n%s", len(tb.failures), 5, strings.Join(tb.failures, "\n"))
+	}
+	for _, failure := range tb.failures {
+		if !strings.Contains(failure, "Result:\n  code: success") {
+			t.Errorf("failure does not contain the full result\ngot: %s", failure)
+		}
+	}
+}
+
+func TestAssertResultErrors(t *testing.T) {
+	res := orchestrator.Process(context.Background(), &teamSO{}, newTeamRequest(t, 1))
+	orchestratortest.AssertResult(t, res).
+		Code(orchestrator.ResultCodeError).
+		ErrorContains("cannot unmarshal number")
+
+	tb := &recordingTB{TB: t}
+	orchestratortest.AssertResult(tb, res).NoErrors()
+	if len(tb.failures) != 1 || !strings.Contains(tb.failures[0], "errors:\n  [0] manifesthandler") {
+		t.Errorf("failure does not contain the internal errors\ngot: %q", tb.failures)
+	}
+}
+
+func TestAssertResultStackTrace(t *testing.T) {
+	// Render stacks in a predictable way, instead of using the marshaler registered by go-logging
+	marshaler := zerolog.ErrorStackMarshaler
+	t.Cleanup(func() {
+		zerolog.ErrorStackMarshaler = marshaler
+	})
+	zerolog.ErrorStackMarshaler = func(err error) any {
+		return "mockstack\nof " + err.Error()
+	}
+
+	res := &orchestrator.Result{}
+	res.Succeed("")
+	res.Succeed("")
+
+	tb := &recordingTB{TB: t}
+	orchestratortest.AssertResult(tb, res).NoErrors()
+	if len(tb.failures) != 1 {
+		t.Fatalf("number of failures does not match expected value\ngot: %d\nwant: %d", len(tb.failures), 1)
+	}
+
+	failure := tb.failures[0]
+	want := "  [0] attempted to mark a locked result as succeeded\n      mockstack\n      of attempted to mark a locked result as succeeded\n"
+	if !strings.Contains(failure, want) {
+		t.Errorf("failure does not contain the stack trace of the error\ngot:\n%s\nwant:\n%s", failure, want)
+	}
+}
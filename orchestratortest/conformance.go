@@ -0,0 +1,147 @@
+package orchestratortest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/entur/go-orchestrator"
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------
+// Conformance
+// -----------------------
+
+// Names of the conformance checks.
+const (
+	CheckLocked              = "locked"                // Every action calls .Succeed(msg) or .Fail(msg)
+	CheckNoInternalErrors    = "no-internal-errors"    // Valid fixtures never result in an internal error
+	CheckDeterministicPlan   = "deterministic-plan"    // Planning the same manifest twice gives the same result
+	CheckPlanApplyConsistent = "plan-apply-consistent" // Applying gives the same changes as planning
+	CheckIdempotentApply     = "idempotent-apply"      // Applying an already applied manifest is a noop
+	CheckDestroyConsistent   = "destroy-consistent"    // Destroying gives the same changes as planning the destruction
+)
+
+// The Violation type represents a failed conformance check.
+type Violation struct {
+	Check   string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("[%s] %s", v.Check, v.Message)
+}
+
+// Conformance runs every manifest handler of the sub-orchestrator through every action, using the fixtures matching its
+// APIVersion and Kind, and reports all violations per handler. Fixtures are manifests, which are marshalled to JSON.
+// Every fixture is expected to be a valid manifest, and every handler is expected to have at least one fixture.
+func Conformance(t *testing.T, so orchestrator.Orchestrator, fixtures []any) {
+	t.Helper()
+
+	byHandler := map[orchestrator.ManifestHeader][]orchestrator.Manifest{}
+	for i, fixture := range fixtures {
+		manifest, err := json.Marshal(fixture)
+		if err != nil {
+			t.Fatalf("unable to marshal fixture %d: %s", i, err)
+		}
+
+		var header orchestrator.ManifestHeader
+		err = json.Unmarshal(manifest, &header)
+		if err != nil {
+			t.Fatalf("unable to unmarshal the header of fixture %d: %s", i, err)
+		}
+		byHandler[header] = append(byHandler[header], manifest)
+	}
+
+	for _, handler := range so.Handlers() {
+		header := orchestrator.ManifestHeader{APIVersion: handler.APIVersion(), Kind: handler.Kind()}
+		manifests := byHandler[header]
+		delete(byHandler, header)
+
+		t.Run(fmt.Sprintf("%s/%s", header.APIVersion, header.Kind), func(t *testing.T) {
+			t.Helper()
+
+			if len(manifests) == 0 {
+				t.Errorf("no fixtures found for handler (%s, %s)", header.APIVersion, header.Kind)
+				return
+			}
+
+			for i, manifest := range manifests {
+				for _, violation := range CheckConformance(context.Background(), so, manifest) {
+					t.Errorf("fixture %d: %s", i, violation)
+				}
+			}
+		})
+	}
+
+	for header := range byHandler {
+		t.Errorf("no handler found for fixtures with apiVersion '%s' and kind '%s'", header.APIVersion, header.Kind)
+	}
+}
+
+// CheckConformance runs the manifest through a full lifecycle, and returns all violated conformance checks.
+func CheckConformance(ctx context.Context, so orchestrator.Orchestrator, manifest orchestrator.Manifest) []Violation {
+	lifecycle := NewLifecycle(so)
+	results, err := lifecycle.Run(ctx,
+		Plan(manifest),
+		Plan(manifest),
+		Apply(manifest),
+		Apply(manifest),
+		PlanDestroy(),
+		Destroy(),
+	)
+	if err != nil {
+		// Only happens if the first apply did not succeed, in which case there is nothing to destroy
+		results = lifecycle.Results()
+	}
+
+	var violations []Violation
+	for _, step := range results {
+		res := step.Result
+		if !res.Locked() {
+			violations = append(violations, Violation{CheckLocked, fmt.Sprintf("%s did not call .Succeed(msg) or .Fail(msg)\n%s", step.Step.Action, FormatResult(res))})
+		} else if res.Code() == orchestrator.ResultCodeError {
+			violations = append(violations, Violation{CheckNoInternalErrors, fmt.Sprintf("%s returned an internal error\n%s", step.Step.Action, FormatResult(res))})
+		}
+	}
+	if len(results) < 4 {
+		return violations
+	}
+
+	compare := func(check string, a StepResult, b StepResult) {
+		want, got := changeSummary(a.Result), changeSummary(b.Result)
+		if want != got {
+			violations = append(violations, Violation{check, fmt.Sprintf("%s and %s results do not match\n%s", a.Step.Action, b.Step.Action, diff(want, got))})
+		}
+	}
+
+	compare(CheckDeterministicPlan, results[0], results[1])
+	compare(CheckPlanApplyConsistent, results[0], results[2])
+
+	// A second apply can only be a noop if the first one was applied
+	applied := results[2].Result.Code() == orchestrator.ResultCodeSuccess || results[2].Result.Code() == orchestrator.ResultCodeNoop
+	if applied && results[3].Result.Code() != orchestrator.ResultCodeNoop {
+		violations = append(violations, Violation{CheckIdempotentApply, fmt.Sprintf("applying an already applied manifest is not a noop\n%s", FormatResult(results[3].Result))})
+	}
+
+	if len(results) == 6 {
+		compare(CheckDestroyConsistent, results[4], results[5])
+	}
+
+	return violations
+}
+
+// changeSummary renders the result code and changes, ignoring the output as it usually differs between planning and applying.
+func changeSummary(res *orchestrator.Result) string {
+	golden := NewGoldenResult(res)
+	golden.Output = ""
+	golden.Errors = nil
+
+	enc, err := yaml.Marshal(golden)
+	if err != nil {
+		return err.Error()
+	}
+	return string(enc)
+}
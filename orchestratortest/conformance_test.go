@@ -0,0 +1,65 @@
+package orchestratortest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/entur/go-orchestrator"
+	"github.com/entur/go-orchestrator/orchestratortest"
+)
+
+// sloppyTeamHandler forgets to lock the result when destroying, and creates the team again on every apply.
+type sloppyTeamHandler struct {
+	teamHandler
+	applies int
+}
+
+func (h *sloppyTeamHandler) Apply(_ context.Context, _ orchestrator.Request, r *orchestrator.Result) error {
+	h.applies++
+	r.Create("Team platform")
+	r.Succeed("")
+	return nil
+}
+
+func (h *sloppyTeamHandler) Destroy(_ context.Context, _ orchestrator.Request, r *orchestrator.Result) error {
+	r.Delete("Team platform")
+	return nil
+}
+
+type sloppyTeamSO struct {
+	handler *sloppyTeamHandler
+}
+
+func (so *sloppyTeamSO) Handlers() []orchestrator.ManifestHandler {
+	return []orchestrator.ManifestHandler{so.handler}
+}
+
+func TestConformance(t *testing.T) {
+	orchestratortest.Conformance(t, &teamSO{}, []any{
+		newTeamRequest(t, "platform").Manifest.New,
+		newTeamRequest(t, "platform-team").Manifest.New,
+	})
+}
+
+func TestCheckConformance(t *testing.T) {
+	so := &sloppyTeamSO{handler: &sloppyTeamHandler{}}
+	violations := orchestratortest.CheckConformance(context.Background(), so, newTeamRequest(t, "platform").Manifest.New)
+
+	checks := map[string]bool{}
+	for _, violation := range violations {
+		checks[violation.Check] = true
+	}
+
+	expected := []string{orchestratortest.CheckLocked, orchestratortest.CheckIdempotentApply, orchestratortest.CheckDestroyConsistent}
+	for _, check := range expected {
+		if !checks[check] {
+			t.Errorf("violated check was not reported\ngot: %v\nwant: %s", violations, check)
+		}
+	}
+	if len(checks) != len(expected) {
+		t.Errorf("number of violated checks does not match expected value\ngot: %v\nwant: %v", violations, expected)
+	}
+	if so.handler.applies != 2 {
+		t.Errorf("number of applies does not match expected value\ngot: %d\nwant: %d", so.handler.applies, 2)
+	}
+}
@@ -0,0 +1,16 @@
+package orchestratortest
+
+import (
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	want := "code: success\noutput: a\ncreations:\n    - b\n"
+	got := "code: failure\noutput: a\n"
+
+	expected := "- code: success\n+ code: failure\n  output: a\n- creations:\n-     - b\n  \n"
+	result := diff(want, got)
+	if result != expected {
+		t.Errorf("diff does not match expected value\ngot:\n%s\nwant:\n%s", result, expected)
+	}
+}
@@ -0,0 +1,137 @@
+package orchestratortest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/pubsub/v2"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/v2/pstest"
+	"github.com/entur/go-orchestrator"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// -----------------------
+// End-to-End
+// -----------------------
+
+const DefaultE2EProject = "mockproject" // Default project of the fake Pub/Sub server used in end-to-end tests.
+
+// The E2E type runs a sub-orchestrator Handler against a fake Pub/Sub server, covering the whole path from
+// CloudEvent to published Response: unmarshalling, processing, response topic selection and publishing.
+type E2E struct {
+	Server  *pstest.Server
+	Client  *pubsub.Client
+	Handler *orchestrator.Handler
+
+	mu     sync.Mutex
+	topics map[string]bool // Response topics which have been created on the fake server
+}
+
+// NewE2E starts a fake Pub/Sub server and creates a Handler for the sub-orchestrator publishing to it.
+// The handler, client and server are closed when the test finishes.
+func NewE2E(t testing.TB, so orchestrator.Orchestrator, opts ...orchestrator.HandlerOption) *E2E {
+	t.Helper()
+
+	srv := pstest.NewServer()
+	t.Cleanup(func() {
+		_ = srv.Close()
+	})
+
+	client, err := pubsub.NewClient(context.Background(), DefaultE2EProject,
+		option.WithEndpoint(srv.Addr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	h, err := orchestrator.NewHandler(so, append(opts, orchestrator.WithCustomPubSubClient(client))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cleanups run in reverse order, so the handler is closed before the client
+	t.Cleanup(func() {
+		_ = h.Close(context.Background())
+	})
+
+	return &E2E{
+		Server:  srv,
+		Client:  client,
+		Handler: h,
+		topics:  map[string]bool{},
+	}
+}
+
+// Send sends a mock CloudEvent for the manifest to the handler, and returns the Response published to the response topic.
+// The base64 encoded Output of the response is decoded. An error is returned if the handler returned an error, or no response was published.
+func (e *E2E) Send(ctx context.Context, manifest any, opts ...orchestrator.MockRequestOption) (*orchestrator.Response, error) {
+	req, err := orchestrator.NewMockRequest(manifest, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := orchestrator.NewMockCloudEvent(manifest, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	topic := fmt.Sprintf("projects/%s/topics/%s", DefaultE2EProject, req.ResponseTopic)
+	if !e.topics[topic] {
+		_, err = e.Client.TopicAdminClient.CreateTopic(ctx, &pubsubpb.Topic{Name: topic})
+		if err != nil {
+			return nil, fmt.Errorf("unable to create response topic: %w", err)
+		}
+		e.topics[topic] = true
+	}
+
+	published := map[string]bool{}
+	for _, msg := range e.Server.Messages() {
+		published[msg.ID] = true
+	}
+
+	err = e.Handler.Handle(ctx, *event)
+	if err != nil {
+		return nil, fmt.Errorf("handler returned an error: %w", err)
+	}
+
+	for _, msg := range e.Server.Messages() {
+		if published[msg.ID] || msg.Topic != topic {
+			continue
+		}
+		return DecodeResponse(msg.Data)
+	}
+
+	return nil, fmt.Errorf("no response was published to topic '%s'", topic)
+}
+
+// DecodeResponse decodes a published Response, including its base64 encoded Output.
+func DecodeResponse(data []byte) (*orchestrator.Response, error) {
+	var res orchestrator.Response
+
+	err := json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode response: %w", err)
+	}
+
+	output, err := base64.StdEncoding.DecodeString(res.Output)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode response output: %w", err)
+	}
+	res.Output = string(output)
+
+	return &res, nil
+}
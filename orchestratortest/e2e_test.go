@@ -0,0 +1,62 @@
+package orchestratortest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/entur/go-orchestrator"
+	"github.com/entur/go-orchestrator/orchestratortest"
+	"github.com/rs/zerolog"
+)
+
+func TestE2E(t *testing.T) {
+	e2e := orchestratortest.NewE2E(t, &teamSO{}, orchestrator.WithCustomLogger(zerolog.Nop()))
+
+	type Expected struct {
+		code   orchestrator.ResultCode
+		output string
+	}
+
+	type Test struct {
+		title    string
+		name     any
+		opts     []orchestrator.MockRequestOption
+		expected Expected
+	}
+
+	var tests = []Test{
+		{
+			title:    "plan",
+			name:     "platform",
+			expected: Expected{code: orchestrator.ResultCodeSuccess, output: "+ Team platform"},
+		},
+		{
+			title:    "user error on a different response topic",
+			name:     "",
+			opts:     []orchestrator.MockRequestOption{orchestrator.WithResponseTopic("othertopic")},
+			expected: Expected{code: orchestrator.ResultCodeFailure, output: "/spec/name: is required"},
+		},
+	}
+
+	for _, test := range tests {
+		tmp := test
+		t.Run(tmp.title, func(t *testing.T) {
+			req := newTeamRequest(t, tmp.name)
+
+			res, err := e2e.Send(context.Background(), req.Manifest.New, tmp.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.ResultCode != tmp.expected.code {
+				t.Errorf("result code does not match expected value\ngot: %s\nwant: %s", res.ResultCode, tmp.expected.code)
+			}
+			if !strings.Contains(res.Output, tmp.expected.output) {
+				t.Errorf("output does not contain expected value\ngot: %s\nwant: %s", res.Output, tmp.expected.output)
+			}
+			if res.Metadata.RequestID != orchestrator.DefaultMockRequestID {
+				t.Errorf("request id does not match expected value\ngot: %s\nwant: %s", res.Metadata.RequestID, orchestrator.DefaultMockRequestID)
+			}
+		})
+	}
+}
@@ -0,0 +1,489 @@
+package orchestratortest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/entur/go-orchestrator"
+)
+
+// -----------------------
+// Fuzzing
+// -----------------------
+
+const (
+	fuzzMaxDepth       = 8    // Maximum nesting of generated values
+	fuzzHugeLength     = 1024 // Length of generated huge arrays and strings
+	fuzzMaxLength      = 8    // Maximum length of regular generated arrays and maps
+	fuzzMaxStrLength   = 32   // Maximum length of regular generated strings, unless the schema says otherwise
+	fuzzMaxMutations   = 4    // Maximum number of mutations applied to a seed manifest
+	fuzzOneIn          = 16   // Probability (1 in N) of generating something unexpected, such as a null
+	fuzzNumberVariance = 1000 // Range of generated numbers outside the schema bounds
+)
+
+// Kinds of fuzzing findings.
+const (
+	FindingPanic         = "panic"          // A middleware or manifest handler panicked
+	FindingInternalError = "internal-error" // The result code was 'error'
+	FindingUnlocked      = "unlocked"       // The result was never marked as succeeded or failed
+)
+
+// The Finding type represents a problem found when fuzzing a sub-orchestrator.
+type Finding struct {
+	Kind   string
+	Action orchestrator.Action
+	Result *orchestrator.Result
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("[%s] %s\n%s", f.Kind, f.Action, FormatResult(f.Result))
+}
+
+// CheckFindings processes the manifest with every action, and returns all findings.
+// User errors ('failure' results) are not findings, as most fuzzed manifests are expected to be invalid.
+func CheckFindings(ctx context.Context, so orchestrator.Orchestrator, manifest orchestrator.Manifest) ([]Finding, error) {
+	var findings []Finding
+
+	for _, action := range orchestrator.Actions() {
+		req, err := orchestrator.NewMockRequest(manifest, orchestrator.WithAction(action))
+		if err != nil {
+			return nil, err
+		}
+
+		res := orchestrator.Process(ctx, so, req)
+
+		var kind string
+		switch {
+		case slices.ContainsFunc(res.Errors(), func(err error) bool { return errors.Is(err, orchestrator.ErrPanic) }):
+			kind = FindingPanic
+		case !res.Locked():
+			kind = FindingUnlocked
+		case res.Code() == orchestrator.ResultCodeError:
+			kind = FindingInternalError
+		default:
+			continue
+		}
+		findings = append(findings, Finding{Kind: kind, Action: action, Result: res})
+	}
+
+	return findings, nil
+}
+
+// Fuzz drives manifests through the sub-orchestrator using Go's native fuzzing, and reports every finding.
+// Manifests are either generated from the schema, or random mutations of the seed manifests, see ManifestFuzzer.
+//
+//	func FuzzAirplane(f *testing.F) {
+//		orchestratortest.Fuzz(f, &MySubOrchestrator{}, &AirplaneManifestHandler{}, AirplaneManifest{}, seedManifest)
+//	}
+func Fuzz(f *testing.F, so orchestrator.Orchestrator, handler orchestrator.ManifestHandler, schema any, seeds ...any) {
+	f.Helper()
+
+	fuzzer, err := NewManifestFuzzer(handler, schema, seeds...)
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	// Seed the corpus with every seed manifest unchanged, and a few generated manifests
+	for i := range seeds {
+		f.Add([]byte{fuzzModeSeed, 0, byte(i), 0, 0})
+	}
+	for i := range 4 {
+		f.Add([]byte{fuzzModeGenerate, byte(i)})
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		manifest := fuzzer.Manifest(data)
+
+		findings, err := CheckFindings(context.Background(), so, manifest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, finding := range findings {
+			t.Errorf("manifest: %s\n%s", manifest, finding)
+		}
+	})
+}
+
+const (
+	fuzzModeGenerate byte = iota
+	fuzzModeSeed
+)
+
+// The ManifestFuzzer type turns fuzzing input into manifests for a specific handler.
+//
+// Manifests are generated from the schema, which is a (zero) value of the Go type the manifest is unmarshalled into.
+// Its 'json' tags define the field names, and its 'jsonschema' tags the constraints: 'required', 'minimum', 'maximum',
+// 'minLength', 'maxLength' and 'enum'. Generated values mostly respect the constraints, but occasionally violate them,
+// and fields are occasionally null or huge.
+//
+// Seed manifests are mutated by setting fields to null, removing them, replacing them with values of a different type,
+// or replacing them with huge arrays.
+type ManifestFuzzer struct {
+	header orchestrator.ManifestHeader
+	schema reflect.Type
+	seeds  []any
+}
+
+// NewManifestFuzzer returns a fuzzer for manifests of the handler.
+func NewManifestFuzzer(handler orchestrator.ManifestHandler, schema any, seeds ...any) (*ManifestFuzzer, error) {
+	fuzzer := &ManifestFuzzer{
+		header: orchestrator.ManifestHeader{APIVersion: handler.APIVersion(), Kind: handler.Kind()},
+		schema: reflect.TypeOf(schema),
+	}
+
+	for i, seed := range seeds {
+		enc, err := json.Marshal(seed)
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal seed %d: %w", i, err)
+		}
+		var value any
+		err = json.Unmarshal(enc, &value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to unmarshal seed %d: %w", i, err)
+		}
+		fuzzer.seeds = append(fuzzer.seeds, value)
+	}
+
+	return fuzzer, nil
+}
+
+// Manifest deterministically turns the fuzzing input into a manifest.
+func (m *ManifestFuzzer) Manifest(data []byte) orchestrator.Manifest {
+	src := &byteSource{data: data}
+
+	var value any
+	if src.byte()%2 == fuzzModeSeed && len(m.seeds) > 0 {
+		value = deepCopy(m.seeds[src.intn(len(m.seeds))])
+		for range src.intn(fuzzMaxMutations + 1) {
+			value = mutate(src, value, 0)
+		}
+	} else {
+		value = generate(src, m.schema, jsonschemaTags{}, 0)
+	}
+
+	obj, ok := value.(map[string]any)
+	if !ok {
+		obj = map[string]any{"value": value}
+	}
+
+	// Keep the header intact most of the time, as the handler is never reached otherwise
+	if !src.oneIn(fuzzOneIn) {
+		obj["apiVersion"] = m.header.APIVersion
+		obj["kind"] = m.header.Kind
+	}
+
+	return marshalFuzzed(obj)
+}
+
+func marshalFuzzed(value any) orchestrator.Manifest {
+	enc, err := json.Marshal(value)
+	if err != nil {
+		// Only happens for NaN and infinite numbers, which are never generated
+		return orchestrator.Manifest("null")
+	}
+	return enc
+}
+
+// byteSource consumes fuzzing input as a source of randomness. Once the input is exhausted, it returns zeroes.
+type byteSource struct {
+	data      []byte
+	pos       int
+	generated bool // If a huge value has been generated, as nesting them would grow exponentially
+}
+
+func (s *byteSource) byte() byte {
+	if s.pos >= len(s.data) {
+		return 0
+	}
+	b := s.data[s.pos]
+	s.pos++
+	return b
+}
+
+func (s *byteSource) intn(n int) int {
+	if n <= 1 {
+		return 0
+	}
+	v := int(s.byte())<<8 | int(s.byte())
+	return v % n
+}
+
+func (s *byteSource) oneIn(n int) bool {
+	return s.intn(n) == n-1
+}
+
+// huge reports whether the next value should be huge. At most one huge value is generated per manifest.
+func (s *byteSource) huge() bool {
+	if s.generated || !s.oneIn(fuzzOneIn) {
+		return false
+	}
+	s.generated = true
+	return true
+}
+
+type jsonschemaTags struct {
+	required  bool
+	minimum   *float64
+	maximum   *float64
+	minLength *int
+	maxLength *int
+	enum      []string
+}
+
+func parseJSONSchemaTags(tag string) jsonschemaTags {
+	var tags jsonschemaTags
+
+	for _, part := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "required":
+			tags.required = true
+		case "minimum":
+			if v, err := strconv.ParseFloat(value, 64); err == nil {
+				tags.minimum = &v
+			}
+		case "maximum":
+			if v, err := strconv.ParseFloat(value, 64); err == nil {
+				tags.maximum = &v
+			}
+		case "minLength":
+			if v, err := strconv.Atoi(value); err == nil {
+				tags.minLength = &v
+			}
+		case "maxLength":
+			if v, err := strconv.Atoi(value); err == nil {
+				tags.maxLength = &v
+			}
+		case "enum":
+			tags.enum = append(tags.enum, value)
+		default:
+		}
+	}
+
+	return tags
+}
+
+func generate(src *byteSource, t reflect.Type, tags jsonschemaTags, depth int) any {
+	if t == nil || depth > fuzzMaxDepth || src.oneIn(fuzzOneIn) {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return generate(src, t.Elem(), tags, depth)
+	case reflect.Struct:
+		obj := map[string]any{}
+		generateFields(src, t, obj, depth)
+		return obj
+	case reflect.Map:
+		obj := map[string]any{}
+		for i := range src.intn(fuzzMaxLength) {
+			obj[fmt.Sprintf("key%d", i)] = generate(src, t.Elem(), jsonschemaTags{}, depth+1)
+		}
+		return obj
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return generateString(src, tags)
+		}
+		length := src.intn(fuzzMaxLength)
+		if src.huge() {
+			length = fuzzHugeLength
+		}
+		arr := make([]any, 0, length)
+		for range length {
+			arr = append(arr, generate(src, t.Elem(), jsonschemaTags{}, depth+1))
+		}
+		return arr
+	case reflect.String:
+		return generateString(src, tags)
+	case reflect.Bool:
+		return src.byte()%2 == 1
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return math.Round(generateNumber(src, tags))
+	case reflect.Float32, reflect.Float64:
+		return generateNumber(src, tags)
+	case reflect.Interface:
+		return generateScalar(src)
+	default:
+		return nil
+	}
+}
+
+// generateFields adds the fields of the struct type to obj, flattening embedded structs the way encoding/json does.
+func generateFields(src *byteSource, t reflect.Type, obj map[string]any, depth int) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				generateFields(src, ft, obj, depth)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		tags := parseJSONSchemaTags(field.Tag.Get("jsonschema"))
+		if !tags.required && src.byte()%2 == 0 {
+			continue
+		}
+		obj[name] = generate(src, field.Type, tags, depth+1)
+	}
+}
+
+func generateString(src *byteSource, tags jsonschemaTags) string {
+	if len(tags.enum) > 0 && !src.oneIn(fuzzOneIn) {
+		return tags.enum[src.intn(len(tags.enum))]
+	}
+
+	minLength, maxLength := 0, fuzzMaxStrLength
+	if tags.minLength != nil {
+		minLength = *tags.minLength
+	}
+	if tags.maxLength != nil {
+		maxLength = min(*tags.maxLength, fuzzHugeLength)
+	}
+
+	length := minLength + src.intn(max(maxLength-minLength, 0)+1)
+	if src.huge() {
+		length = fuzzHugeLength
+	}
+
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./: æøå\"\\\n\t{}[]"
+	runes := []rune(alphabet)
+	var builder strings.Builder
+	for range length {
+		builder.WriteRune(runes[src.intn(len(runes))])
+	}
+	return builder.String()
+}
+
+func generateNumber(src *byteSource, tags jsonschemaTags) float64 {
+	lower, upper := -float64(fuzzNumberVariance), float64(fuzzNumberVariance)
+	if tags.minimum != nil {
+		lower = *tags.minimum
+	}
+	if tags.maximum != nil {
+		upper = *tags.maximum
+	}
+
+	// Occasionally step outside of the bounds
+	if src.oneIn(fuzzOneIn) {
+		lower -= fuzzNumberVariance
+		upper += fuzzNumberVariance
+	}
+
+	fraction := float64(src.intn(math.MaxUint16)) / float64(math.MaxUint16-1)
+	return lower + fraction*(upper-lower)
+}
+
+func generateScalar(src *byteSource) any {
+	switch src.intn(4) {
+	case 0:
+		return generateString(src, jsonschemaTags{})
+	case 1:
+		return generateNumber(src, jsonschemaTags{})
+	case 2:
+		return src.byte()%2 == 1
+	default:
+		return map[string]any{}
+	}
+}
+
+// mutate applies a single random mutation somewhere in the value, and returns the mutated value.
+func mutate(src *byteSource, value any, depth int) any {
+	switch v := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		if len(keys) == 0 || depth > fuzzMaxDepth || src.oneIn(len(keys)+1) {
+			return mutateValue(src, value)
+		}
+
+		// Map iteration order is random, so sort the keys to keep mutations deterministic
+		slices.Sort(keys)
+		key := keys[src.intn(len(keys))]
+		if src.oneIn(fuzzMaxMutations) {
+			delete(v, key)
+		} else {
+			v[key] = mutate(src, v[key], depth+1)
+		}
+		return v
+	case []any:
+		if len(v) == 0 || depth > fuzzMaxDepth || src.oneIn(len(v)+1) {
+			return mutateValue(src, value)
+		}
+		i := src.intn(len(v))
+		v[i] = mutate(src, v[i], depth+1)
+		return v
+	default:
+		return mutateValue(src, value)
+	}
+}
+
+func mutateValue(src *byteSource, value any) any {
+	switch src.intn(5) {
+	case 0:
+		return nil
+	case 1:
+		if src.generated {
+			return nil
+		}
+		src.generated = true
+
+		huge := make([]any, fuzzHugeLength)
+		for i := range huge {
+			huge[i] = deepCopy(value)
+		}
+		return huge
+	case 2:
+		return generateString(src, jsonschemaTags{})
+	case 3:
+		return generateNumber(src, jsonschemaTags{})
+	default:
+		return generateScalar(src)
+	}
+}
+
+func deepCopy(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		obj := make(map[string]any, len(v))
+		for key, elem := range v {
+			obj[key] = deepCopy(elem)
+		}
+		return obj
+	case []any:
+		arr := make([]any, len(v))
+		for i, elem := range v {
+			arr[i] = deepCopy(elem)
+		}
+		return arr
+	default:
+		return v
+	}
+}
@@ -0,0 +1,107 @@
+package orchestratortest_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/entur/go-orchestrator"
+	"github.com/entur/go-orchestrator/orchestratortest"
+)
+
+type fuzzTeamManifest struct {
+	orchestrator.ManifestHeader
+	Spec struct {
+		Name    string   `json:"name" jsonschema:"required,minLength=2,maxLength=8"`
+		Members []string `json:"members"`
+		Size    int      `json:"size" jsonschema:"required,minimum=1,maximum=10"`
+	} `json:"spec" jsonschema:"required"`
+}
+
+// fragileTeamHandler panics when a team has more members than its size.
+type fragileTeamHandler struct {
+	teamHandler
+}
+
+func (h *fragileTeamHandler) Plan(_ context.Context, req orchestrator.Request, r *orchestrator.Result) error {
+	var manifest fuzzTeamManifest
+	err := json.Unmarshal(req.Manifest.New, &manifest)
+	if err != nil {
+		r.Fail(err.Error())
+		return nil
+	}
+
+	sizes := make([]int, manifest.Spec.Size)
+	for i := range manifest.Spec.Members {
+		sizes[i]++
+	}
+	r.Succeed("")
+	return nil
+}
+
+type fragileTeamSO struct{}
+
+func (so *fragileTeamSO) Handlers() []orchestrator.ManifestHandler {
+	return []orchestrator.ManifestHandler{&fragileTeamHandler{}}
+}
+
+func TestManifestFuzzer(t *testing.T) {
+	fuzzer, err := orchestratortest.NewManifestFuzzer(&teamHandler{}, fuzzTeamManifest{}, newTeamRequest(t, "platform").Manifest.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := [][]byte{
+		nil,
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 0, 0, 0, 3, 0, 0, 0, 1, 0, 2, 0, 4},
+		{1, 255, 255, 255, 255, 255, 255, 255, 255},
+	}
+	for _, input := range inputs {
+		manifest := fuzzer.Manifest(input)
+		if string(manifest) != string(fuzzer.Manifest(input)) {
+			t.Errorf("fuzzed manifest is not deterministic for input %v", input)
+		}
+
+		var header orchestrator.ManifestHeader
+		err = json.Unmarshal(manifest, &header)
+		if err != nil {
+			t.Errorf("fuzzed manifest is not a valid JSON object\ngot: %s", manifest)
+		}
+	}
+
+	// Without any input, only required fields are generated, with their minimum values
+	want := `{"apiVersion":"orchestrator.entur.io/test/v1","kind":"Team","spec":{"name":"aa","size":1}}`
+	if got := string(fuzzer.Manifest(nil)); got != want {
+		t.Errorf("fuzzed manifest does not match expected value\ngot: %s\nwant: %s", got, want)
+	}
+}
+
+func TestCheckFindings(t *testing.T) {
+	manifest := []byte(`{"apiVersion":"orchestrator.entur.io/test/v1","kind":"Team","spec":{"name":"aa","size":1,"members":["a","b"]}}`)
+
+	findings, err := orchestratortest.CheckFindings(context.Background(), &fragileTeamSO{}, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 || findings[0].Kind != orchestratortest.FindingPanic || findings[0].Action != orchestrator.ActionPlan {
+		t.Errorf("findings do not match expected value\ngot: %v", findings)
+	}
+
+	findings, err = orchestratortest.CheckFindings(context.Background(), &teamSO{}, newTeamRequest(t, "platform").Manifest.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("findings reported for a robust handler\ngot: %v", findings)
+	}
+}
+
+func FuzzTeam(f *testing.F) {
+	seed := map[string]any{
+		"apiVersion": "orchestrator.entur.io/test/v1",
+		"kind":       "Team",
+		"spec":       map[string]any{"name": "platform", "size": 2, "members": []string{"a", "b"}},
+	}
+	orchestratortest.Fuzz(f, &teamSO{}, &teamHandler{}, fuzzTeamManifest{}, seed)
+}
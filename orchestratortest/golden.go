@@ -0,0 +1,223 @@
+// Package orchestratortest provides helpers for testing sub-orchestrators.
+package orchestratortest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/entur/go-orchestrator"
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------
+// Golden Files
+// -----------------------
+
+// UpdateGoldenEnv is the environment variable which, when set to true, rewrites golden files with the actual results.
+// This is equivalent to the -orchestratortest.update flag, but also works when testing several packages at once.
+const UpdateGoldenEnv = "ORCHESTRATORTEST_UPDATE"
+
+// The flag is namespaced, so that it does not clash with -update flags defined by the packages under test
+var update = flag.Bool("orchestratortest.update", false, "rewrite golden files with the actual results")
+
+func updateGolden() bool {
+	env, _ := strconv.ParseBool(os.Getenv(UpdateGoldenEnv))
+	return *update || env
+}
+
+// File names used in fixture directories.
+const (
+	FixtureNewManifest = "new"         // The new manifest, with a '.yaml', '.yml' or '.json' extension. Required
+	FixtureOldManifest = "old"         // The old manifest, with a '.yaml', '.yml' or '.json' extension
+	FixtureRequest     = "request"     // Request fields overriding the mock request defaults, with a '.yaml', '.yml' or '.json' extension
+	FixtureGolden      = "golden.yaml" // The expected result
+)
+
+var fixtureExtensions = []string{".yaml", ".yml", ".json"}
+
+// The GoldenResult type is the representation of a Result stored in golden files.
+type GoldenResult struct {
+	Code      orchestrator.ResultCode `yaml:"code"`
+	Output    string                  `yaml:"output"`
+	Creations []string                `yaml:"creations,omitempty"`
+	Updates   []string                `yaml:"updates,omitempty"`
+	Deletions []string                `yaml:"deletions,omitempty"`
+	Errors    []string                `yaml:"errors,omitempty"` // First line of every internal error
+}
+
+// NewGoldenResult converts a result into its golden file representation.
+func NewGoldenResult(res *orchestrator.Result) GoldenResult {
+	golden := GoldenResult{
+		Code:      res.Code(),
+		Output:    res.Output(),
+		Creations: changeStrings(res.Creations()),
+		Updates:   changeStrings(res.Updates()),
+		Deletions: changeStrings(res.Deletions()),
+	}
+
+	for _, err := range res.Errors() {
+		msg, _, _ := strings.Cut(err.Error(), "\n")
+		golden.Errors = append(golden.Errors, msg)
+	}
+
+	return golden
+}
+
+func changeStrings(changes []orchestrator.Change) []string {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(changes))
+	for _, change := range changes {
+		values = append(values, change.String())
+	}
+	return values
+}
+
+// Golden runs every fixture directory below dir through the sub-orchestrator, and compares the results to their golden files.
+// A fixture directory is any directory containing a new manifest, see the Fixture constants for the files it may contain.
+// Run the tests with the -orchestratortest.update flag, or with UpdateGoldenEnv set, to rewrite the golden files with the actual results.
+func Golden(t *testing.T, so orchestrator.Orchestrator, dir string) {
+	t.Helper()
+
+	fixtures, err := FindFixtures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatalf("no fixtures found in '%s'", dir)
+	}
+
+	for _, fixture := range fixtures {
+		name, err := filepath.Rel(dir, fixture)
+		if err != nil {
+			name = fixture
+		}
+
+		t.Run(filepath.ToSlash(name), func(t *testing.T) {
+			t.Helper()
+
+			req, err := LoadFixture(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res := orchestrator.Process(context.Background(), so, req)
+			CompareGolden(t, filepath.Join(fixture, FixtureGolden), NewGoldenResult(res))
+		})
+	}
+}
+
+// FindFixtures returns all fixture directories below dir, in lexical order.
+func FindFixtures(dir string) ([]string, error) {
+	var fixtures []string
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		_, ok := findFixtureFile(path, FixtureNewManifest)
+		if ok {
+			fixtures = append(fixtures, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to find fixtures: %w", err)
+	}
+
+	return fixtures, nil
+}
+
+func findFixtureFile(dir string, name string) (string, bool) {
+	for _, ext := range fixtureExtensions {
+		path := filepath.Join(dir, name+ext)
+		_, err := os.Stat(path)
+		if err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// LoadFixture creates a mock request from the manifests and request overrides in the fixture directory.
+func LoadFixture(dir string, opts ...orchestrator.MockRequestOption) (*orchestrator.Request, error) {
+	newPath, ok := findFixtureFile(dir, FixtureNewManifest)
+	if !ok {
+		return nil, fmt.Errorf("fixture '%s' does not contain a new manifest", dir)
+	}
+
+	oldPath, ok := findFixtureFile(dir, FixtureOldManifest)
+	if ok {
+		oldManifest, _, err := orchestrator.ReadManifestFile(oldPath)
+		if err != nil {
+			return nil, err
+		}
+		opts = append([]orchestrator.MockRequestOption{orchestrator.WithOldManifest(oldManifest)}, opts...)
+	}
+
+	req, err := orchestrator.NewMockRequestFromFile(newPath, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fields in the request file are decoded on top of the mock request, replacing the defaults
+	reqPath, ok := findFixtureFile(dir, FixtureRequest)
+	if ok {
+		overrides, _, err := orchestrator.ReadManifestFile(reqPath)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(overrides, req)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to decode request: %w", reqPath, err)
+		}
+	}
+
+	return req, nil
+}
+
+// CompareGolden compares the result to the golden file at path, and reports a diff if they do not match.
+// If the -orchestratortest.update flag or UpdateGoldenEnv is set, the golden file is rewritten instead.
+func CompareGolden(t testing.TB, path string, got GoldenResult) {
+	t.Helper()
+
+	enc, err := yaml.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if updateGolden() {
+		err = os.WriteFile(path, enc, 0o644) //nolint:gosec // Golden files are checked in along with the tests
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("golden file '%s' does not exist, run the tests with -orchestratortest.update to create it\ngot:\n%s", path, enc)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(want) != string(enc) {
+		t.Errorf("result does not match golden file '%s', run the tests with -orchestratortest.update to rewrite it\n%s", path, diff(string(want), string(enc)))
+	}
+}
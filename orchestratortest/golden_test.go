@@ -0,0 +1,51 @@
+package orchestratortest_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/entur/go-orchestrator"
+	"github.com/entur/go-orchestrator/orchestratortest"
+)
+
+func TestGolden(t *testing.T) {
+	orchestratortest.Golden(t, &teamSO{}, "testdata/golden")
+}
+
+func TestCompareGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), orchestratortest.FixtureGolden)
+	want := orchestratortest.GoldenResult{Code: orchestrator.ResultCodeSuccess, Output: "Created team", Creations: []string{"Team platform"}}
+	got := orchestratortest.GoldenResult{Code: orchestrator.ResultCodeFailure, Output: "Created team"}
+
+	// Updating writes the golden file instead of comparing
+	t.Setenv(orchestratortest.UpdateGoldenEnv, "true")
+	orchestratortest.CompareGolden(t, path, want)
+	t.Setenv(orchestratortest.UpdateGoldenEnv, "")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("golden file was not written: %s", err)
+	}
+	if !strings.Contains(string(data), "code: success") {
+		t.Errorf("golden file does not contain the result\ngot:\n%s", data)
+	}
+
+	// Matching results pass
+	orchestratortest.CompareGolden(t, path, want)
+
+	// Mismatching results are reported as a diff against the golden file
+	tb := &recordingTB{TB: t}
+	orchestratortest.CompareGolden(tb, path, got)
+	if len(tb.failures) != 1 {
+		t.Fatalf("number of failures does not match expected value\ngot: %d\nwant: %d", len(tb.failures), 1)
+	}
+
+	failure := tb.failures[0]
+	for _, line := range []string{"does not match golden file", "- code: success", "+ code: failure", "  output: Created team", "- creations:", "-     - Team platform"} {
+		if !strings.Contains(failure, line) {
+			t.Errorf("failure does not contain expected value\ngot:\n%s\nwant: %s", failure, line)
+		}
+	}
+}
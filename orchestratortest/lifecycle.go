@@ -0,0 +1,132 @@
+package orchestratortest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/entur/go-orchestrator"
+)
+
+// -----------------------
+// Lifecycle
+// -----------------------
+
+// The Step type represents a single user action in a manifest lifecycle.
+type Step struct {
+	Action   orchestrator.Action
+	Manifest any // The new manifest. If nil, the currently applied manifest is used, as when a manifest file is deleted
+	Opts     []orchestrator.MockRequestOption
+}
+
+// Plan returns a step planning the given manifest, as when a pull request is opened or updated.
+func Plan(manifest any, opts ...orchestrator.MockRequestOption) Step {
+	return Step{Action: orchestrator.ActionPlan, Manifest: manifest, Opts: opts}
+}
+
+// Apply returns a step applying the given manifest, as when a pull request is merged.
+func Apply(manifest any, opts ...orchestrator.MockRequestOption) Step {
+	return Step{Action: orchestrator.ActionApply, Manifest: manifest, Opts: opts}
+}
+
+// PlanDestroy returns a step planning the destruction of the currently applied manifest, as when a manifest file is deleted in a pull request.
+func PlanDestroy(opts ...orchestrator.MockRequestOption) Step {
+	return Step{Action: orchestrator.ActionPlanDestroy, Opts: opts}
+}
+
+// Destroy returns a step destroying the currently applied manifest, as when a pull request deleting a manifest file is merged.
+func Destroy(opts ...orchestrator.MockRequestOption) Step {
+	return Step{Action: orchestrator.ActionDestroy, Opts: opts}
+}
+
+// The StepResult type contains the request sent and result received for a lifecycle step.
+type StepResult struct {
+	Step    Step
+	Request *orchestrator.Request
+	Result  *orchestrator.Result
+}
+
+// The Lifecycle type simulates a sequence of user actions against a sub-orchestrator.
+// Every successfully applied manifest is sent as the old manifest in the following steps, until it is destroyed.
+type Lifecycle struct {
+	so      orchestrator.Orchestrator
+	opts    []orchestrator.MockRequestOption
+	applied orchestrator.Manifest
+	results []StepResult
+}
+
+// NewLifecycle returns a lifecycle simulator for the sub-orchestrator. The options are applied to every request, before the options of each step.
+func NewLifecycle(so orchestrator.Orchestrator, opts ...orchestrator.MockRequestOption) *Lifecycle {
+	return &Lifecycle{so: so, opts: opts}
+}
+
+// Applied returns the currently applied manifest, or nil if there is none.
+func (l *Lifecycle) Applied() orchestrator.Manifest {
+	return l.applied
+}
+
+// Results returns the results of all steps run so far.
+func (l *Lifecycle) Results() []StepResult {
+	results := make([]StepResult, len(l.results))
+	copy(results, l.results)
+	return results
+}
+
+// Run runs the steps in order, and returns their results.
+// An error is only returned if a step could not be run at all, such as when destroying without an applied manifest.
+func (l *Lifecycle) Run(ctx context.Context, steps ...Step) ([]StepResult, error) {
+	results := make([]StepResult, 0, len(steps))
+
+	for i, step := range steps {
+		res, err := l.Step(ctx, step)
+		if err != nil {
+			return results, fmt.Errorf("step %d (%s): %w", i, step.Action, err)
+		}
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// Step runs a single step.
+func (l *Lifecycle) Step(ctx context.Context, step Step) (StepResult, error) {
+	manifest := l.applied
+	if step.Manifest != nil {
+		enc, err := json.Marshal(step.Manifest)
+		if err != nil {
+			return StepResult{}, fmt.Errorf("unable to marshal manifest: %w", err)
+		}
+		manifest = enc
+	}
+	if manifest == nil {
+		return StepResult{}, fmt.Errorf("no manifest given, and no manifest has been applied")
+	}
+
+	opts := append(append([]orchestrator.MockRequestOption{}, l.opts...), orchestrator.WithAction(step.Action))
+	if l.applied != nil {
+		opts = append(opts, orchestrator.WithOldManifest(l.applied))
+	}
+	opts = append(opts, step.Opts...)
+
+	req, err := orchestrator.NewMockRequest(manifest, opts...)
+	if err != nil {
+		return StepResult{}, err
+	}
+
+	res := orchestrator.Process(ctx, l.so, req)
+
+	code := res.Code()
+	if code == orchestrator.ResultCodeSuccess || code == orchestrator.ResultCodeNoop {
+		switch step.Action {
+		case orchestrator.ActionApply:
+			l.applied = req.Manifest.New
+		case orchestrator.ActionDestroy:
+			l.applied = nil
+		default:
+		}
+	}
+
+	result := StepResult{Step: step, Request: req, Result: res}
+	l.results = append(l.results, result)
+	return result, nil
+}
@@ -0,0 +1,54 @@
+package orchestratortest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/entur/go-orchestrator"
+	"github.com/entur/go-orchestrator/orchestratortest"
+)
+
+func TestLifecycle(t *testing.T) {
+	platform := newTeamRequest(t, "platform").Manifest.New
+	renamed := newTeamRequest(t, "platform-team").Manifest.New
+
+	lifecycle := orchestratortest.NewLifecycle(&teamSO{})
+	results, err := lifecycle.Run(context.Background(),
+		orchestratortest.Plan(platform),
+		orchestratortest.Apply(platform),
+		orchestratortest.Plan(platform),
+		orchestratortest.Plan(renamed),
+		orchestratortest.Apply(renamed),
+		orchestratortest.PlanDestroy(),
+		orchestratortest.Destroy(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 7 || len(lifecycle.Results()) != 7 {
+		t.Fatalf("number of results does not match expected value\ngot: %d\nwant: %d", len(results), 7)
+	}
+
+	orchestratortest.AssertResult(t, results[0].Result).Code(orchestrator.ResultCodeSuccess).Creates("Team platform")
+	orchestratortest.AssertResult(t, results[1].Result).Code(orchestrator.ResultCodeSuccess).Creates("Team platform")
+	orchestratortest.AssertResult(t, results[2].Result).Code(orchestrator.ResultCodeNoop).NoChanges()
+	orchestratortest.AssertResult(t, results[3].Result).Code(orchestrator.ResultCodeSuccess).Updates("Team platform -> platform-team")
+	orchestratortest.AssertResult(t, results[4].Result).Code(orchestrator.ResultCodeSuccess).Updates("Team platform -> platform-team")
+	orchestratortest.AssertResult(t, results[5].Result).Code(orchestrator.ResultCodeSuccess).Deletes("Team platform-team")
+	orchestratortest.AssertResult(t, results[6].Result).Code(orchestrator.ResultCodeSuccess).Deletes("Team platform-team")
+
+	if results[0].Request.Manifest.Old != nil {
+		t.Errorf("old manifest was sent before anything was applied")
+	}
+	if results[5].Request.Manifest.Old == nil || string(*results[5].Request.Manifest.Old) != string(renamed) {
+		t.Errorf("old manifest does not match the last applied manifest")
+	}
+	if lifecycle.Applied() != nil {
+		t.Errorf("manifest is still applied after being destroyed")
+	}
+
+	_, err = lifecycle.Step(context.Background(), orchestratortest.Destroy())
+	if err == nil {
+		t.Errorf("destroying without an applied manifest did not return an error")
+	}
+}
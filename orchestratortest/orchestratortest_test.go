@@ -0,0 +1,103 @@
+package orchestratortest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/entur/go-orchestrator"
+)
+
+// -----------------------
+// Test Helpers
+// -----------------------
+
+type TeamManifest struct {
+	orchestrator.ManifestHeader
+	Spec struct {
+		Name string `json:"name"`
+	} `json:"spec"`
+}
+
+type teamHandler struct{}
+
+func (h *teamHandler) APIVersion() orchestrator.APIVersion { return "orchestrator.entur.io/test/v1" }
+func (h *teamHandler) Kind() orchestrator.Kind             { return "Team" }
+
+func (h *teamHandler) changes(req orchestrator.Request, r *orchestrator.Result) error {
+	var manifest TeamManifest
+	err := json.Unmarshal(req.Manifest.New, &manifest)
+	if err != nil {
+		return err
+	}
+	if manifest.Spec.Name == "" {
+		r.Fail(req.FieldError("/spec/name", errors.New("is required")).Error())
+		return nil
+	}
+
+	if req.Manifest.Old == nil {
+		r.Create("Team " + manifest.Spec.Name)
+	} else {
+		var old TeamManifest
+		err = json.Unmarshal(*req.Manifest.Old, &old)
+		if err != nil {
+			return err
+		}
+		if old.Spec.Name != manifest.Spec.Name {
+			r.Update("Team " + old.Spec.Name + " -> " + manifest.Spec.Name)
+		}
+	}
+
+	r.Succeed("Team " + manifest.Spec.Name)
+	return nil
+}
+
+func (h *teamHandler) destroy(req orchestrator.Request, r *orchestrator.Result) error {
+	var manifest TeamManifest
+	err := json.Unmarshal(req.Manifest.New, &manifest)
+	if err != nil {
+		return err
+	}
+
+	r.Delete("Team " + manifest.Spec.Name)
+	r.Succeed("")
+	return nil
+}
+
+func (h *teamHandler) Plan(_ context.Context, req orchestrator.Request, r *orchestrator.Result) error {
+	return h.changes(req, r)
+}
+
+func (h *teamHandler) Apply(_ context.Context, req orchestrator.Request, r *orchestrator.Result) error {
+	return h.changes(req, r)
+}
+
+func (h *teamHandler) PlanDestroy(_ context.Context, req orchestrator.Request, r *orchestrator.Result) error {
+	return h.destroy(req, r)
+}
+
+func (h *teamHandler) Destroy(_ context.Context, req orchestrator.Request, r *orchestrator.Result) error {
+	return h.destroy(req, r)
+}
+
+// newTeamRequest returns a mock request for a team manifest with the given name.
+func newTeamRequest(t *testing.T, name any, opts ...orchestrator.MockRequestOption) *orchestrator.Request {
+	t.Helper()
+
+	req, err := orchestrator.NewMockRequest(map[string]any{
+		"apiVersion": "orchestrator.entur.io/test/v1",
+		"kind":       "Team",
+		"spec":       map[string]any{"name": name},
+	}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+type teamSO struct{}
+
+func (so *teamSO) Handlers() []orchestrator.ManifestHandler {
+	return []orchestrator.ManifestHandler{&teamHandler{}}
+}
@@ -0,0 +1,154 @@
+package oresources
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/entur/go-logging"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/idtoken"
+	"google.golang.org/api/option"
+)
+
+// -----------------------
+// Authentication
+// -----------------------
+
+// The ClientAuth interface represents how a resource client authenticates its requests.
+// See AuthIDToken, AuthTokenSource, AuthBearerToken, AuthNone and AuthDiscover.
+type ClientAuth interface {
+	httpClient(ctx context.Context, name string, audience string) (*http.Client, error)
+}
+
+// ErrNoCredentials is returned by AuthIDToken when no application default credentials which can create id tokens are found.
+var ErrNoCredentials = errors.New("no id token credentials found")
+
+type idTokenAuth struct {
+	opts     []ClientOption
+	required bool
+}
+
+// AuthIDToken authenticates requests with a Google id token for the resource URL, which is what the platform resources
+// expect in production. Unless credentials are given as options, the application default credentials are used, and
+// creating the client fails with ErrNoCredentials if they are missing or cannot create id tokens. Opt in to it to catch
+// misconfigured credentials in production, instead of sending unauthenticated requests like AuthDiscover.
+func AuthIDToken(opts ...ClientOption) ClientAuth {
+	return idTokenAuth{opts: opts, required: true}
+}
+
+// AuthDiscover authenticates requests like AuthIDToken if suitable application default credentials are found, and sends
+// them unauthenticated otherwise, e.g. when running locally against a mock server. This is the default of
+// NewIAMLookupClient, NewJSONClient, NewIAMLookupPool and the clients created by the SDK.
+func AuthDiscover(opts ...ClientOption) ClientAuth {
+	return idTokenAuth{opts: opts}
+}
+
+func (a idTokenAuth) httpClient(ctx context.Context, name string, audience string) (*http.Client, error) {
+	// Credentials given as options are used as is, so that their errors are never mistaken for missing credentials
+	opts := a.opts
+	if len(opts) == 0 {
+		creds, err := findIDTokenCredentials(ctx)
+		if errors.Is(err, ErrNoCredentials) && !a.required {
+			logging.Ctx(ctx).Debug().Err(err).Msgf("Unable to discover idtoken credentials, defaulting to http.Client for %s", name)
+			return newPlainClient(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		opts = []ClientOption{option.WithCredentials(creds)}
+	}
+
+	return idtoken.NewClient(ctx, audience, opts...)
+}
+
+// findIDTokenCredentials returns the application default credentials, if they can be used to create id tokens.
+func findIDTokenCredentials(ctx context.Context) (*google.Credentials, error) {
+	creds, err := google.FindDefaultCredentials(ctx)
+	if err != nil {
+		// Credentials which are explicitly configured, but broken, are never mistaken for missing credentials
+		if os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") != "" {
+			return nil, fmt.Errorf("unable to find application default credentials: %w", err)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrNoCredentials, err)
+	}
+
+	// Credentials from the metadata server have no JSON, and can always create id tokens
+	if len(creds.JSON) == 0 {
+		return creds, nil
+	}
+
+	var file struct {
+		Type idtoken.CredentialsType `json:"type"`
+	}
+	err = json.Unmarshal(creds.JSON, &file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode application default credentials: %w", err)
+	}
+
+	switch file.Type {
+	case idtoken.ServiceAccount, idtoken.ImpersonatedServiceAccount, idtoken.ExternalAccount:
+		return creds, nil
+	default:
+		// E.g. the 'authorized_user' credentials of 'gcloud auth application-default login'
+		return nil, fmt.Errorf("%w: application default credentials of type '%s' cannot create id tokens", ErrNoCredentials, file.Type)
+	}
+}
+
+type tokenSourceAuth struct {
+	source oauth2.TokenSource
+}
+
+// AuthTokenSource authenticates requests with the tokens of the given source, e.g. an impersonated id token source.
+func AuthTokenSource(source oauth2.TokenSource) ClientAuth {
+	return tokenSourceAuth{source: source}
+}
+
+// AuthBearerToken authenticates requests with a static bearer token, e.g. the output of 'gcloud auth print-identity-token'
+// when running locally.
+func AuthBearerToken(token string) ClientAuth {
+	return tokenSourceAuth{source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token, TokenType: "Bearer"})}
+}
+
+func (a tokenSourceAuth) httpClient(_ context.Context, _ string, _ string) (*http.Client, error) {
+	if a.source == nil {
+		return nil, fmt.Errorf("no token source given")
+	}
+
+	return &http.Client{
+		Transport: &oauth2.Transport{
+			Source: oauth2.ReuseTokenSource(nil, a.source),
+			Base:   newPlainTransport(),
+		},
+	}, nil
+}
+
+type noAuth struct{}
+
+// AuthNone sends requests unauthenticated, e.g. against a mock server.
+func AuthNone() ClientAuth {
+	return noAuth{}
+}
+
+func (noAuth) httpClient(_ context.Context, _ string, _ string) (*http.Client, error) {
+	return newPlainClient(), nil
+}
+
+func newPlainTransport() http.RoundTripper {
+	return &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: defaultDialerTimeout,
+		}).DialContext,
+	}
+}
+
+func newPlainClient() *http.Client {
+	return &http.Client{
+		Transport: newPlainTransport(),
+	}
+}
@@ -0,0 +1,115 @@
+package oresources
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestClientAuth(t *testing.T) {
+	tests := []struct {
+		title         string
+		auth          ClientAuth
+		authorization string
+		want          error
+	}{
+		{
+			title:         "Bearer token",
+			auth:          AuthBearerToken("mocktoken"),
+			authorization: "Bearer mocktoken",
+		},
+		{
+			title:         "Token source",
+			auth:          AuthTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "mocktoken"})),
+			authorization: "Bearer mocktoken",
+		},
+		{
+			title:         "Wrong bearer token",
+			auth:          AuthBearerToken("wrongtoken"),
+			authorization: "Bearer wrongtoken",
+			want:          ErrUnauthorized,
+		},
+		{
+			title: "Unauthenticated",
+			auth:  AuthNone(),
+			want:  ErrUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		tmp := test
+		t.Run(tmp.title, func(t *testing.T) {
+			t.Parallel()
+
+			server, _ := newTestMockServer(t, WithUserGroups("mockuser@entur.io", []string{"team-a"}), WithRequiredBearerToken("mocktoken"))
+			httpServer := httptest.NewServer(server.Handler())
+			t.Cleanup(httpServer.Close)
+
+			client, err := NewIAMLookupClientWithAuth(context.Background(), httpServer.URL, tmp.auth)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = client.EntraIDUserGroups(context.Background(), "mockuser@entur.io")
+			if !errors.Is(err, tmp.want) {
+				t.Errorf("lookup error does not match expected value\ngot: %v\nwant: %v", err, tmp.want)
+			}
+
+			requests := server.Requests()
+			if len(requests) != 1 || requests[0].Authorization != tmp.authorization {
+				t.Errorf("recorded authorization does not match expected value\ngot: %v\nwant: %q", requests, tmp.authorization)
+			}
+		})
+	}
+}
+
+func TestClientAuthIDTokenRequired(t *testing.T) {
+	// Hide any application default credentials
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+
+	_, err := NewIAMLookupClientWithAuth(context.Background(), mockIAMLookupURL, AuthIDToken())
+	if !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("client error does not match expected value\ngot: %v\nwant: %v", err, ErrNoCredentials)
+	}
+
+	_, err = NewIAMLookupClientWithAuth(context.Background(), mockIAMLookupURL, AuthDiscover())
+	if err != nil {
+		t.Errorf("discovering client failed without id token credentials: %v", err)
+	}
+}
+
+func TestClientAuthIDTokenUserCredentials(t *testing.T) {
+	// The credentials of 'gcloud auth application-default login' cannot create id tokens
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	err := os.WriteFile(path, []byte(`{"type":"authorized_user","client_id":"mockid","client_secret":"mocksecret","refresh_token":"mocktoken"}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", path)
+
+	_, err = NewIAMLookupClientWithAuth(context.Background(), mockIAMLookupURL, AuthIDToken())
+	if !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("client error does not match expected value\ngot: %v\nwant: %v", err, ErrNoCredentials)
+	}
+
+	_, err = NewIAMLookupClientWithAuth(context.Background(), mockIAMLookupURL, AuthDiscover())
+	if err != nil {
+		t.Errorf("discovering client failed with user credentials: %v", err)
+	}
+}
+
+func TestClientAuthDiscoverBrokenCredentials(t *testing.T) {
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", filepath.Join(t.TempDir(), "missing.json"))
+
+	_, err := NewIAMLookupClientWithAuth(context.Background(), mockIAMLookupURL, AuthDiscover())
+	if err == nil || errors.Is(err, ErrNoCredentials) {
+		t.Errorf("client error does not match expected value\ngot: %v\nwant: an error other than %v", err, ErrNoCredentials)
+	}
+}
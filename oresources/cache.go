@@ -0,0 +1,180 @@
+package oresources
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// -----------------------
+// Caching
+// -----------------------
+
+const DefaultCacheTTL = 5 * time.Minute // Default time a cached lookup is kept.
+const DefaultCacheMaxEntries = 1024     // Default maximum number of cached lookups.
+
+// Upper bound of a lookup shared by concurrent callers, as it is not cancelled along with the caller which started it.
+const sharedLookupTimeout = time.Minute
+
+type cacheEntry struct {
+	key     string
+	value   any
+	expires time.Time
+	element *list.Element // Position in the least recently used list
+}
+
+// The Cache type caches the results of resource client lookups. Failed lookups are never cached.
+// Concurrent identical lookups are deduplicated, so that only one of them is sent to the resource.
+//
+// A cache can either be shared by all requests (process-wide), in which case the TTL bounds how stale a result can be,
+// or created for a single request, so that repeated lookups in different middlewares only cost one round trip.
+// Lookups are keyed by the resource URL as well, so a cache can also be shared by clients for different resources.
+type Cache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*cacheEntry
+	lru        *list.List // Most recently used first
+	group      singleflight.Group
+	now        func() time.Time
+}
+
+type CacheOption func(*Cache)
+
+// Set how long lookups are cached. A TTL of zero or less caches lookups forever.
+func WithCacheTTL(ttl time.Duration) CacheOption {
+	return func(c *Cache) {
+		c.ttl = ttl
+	}
+}
+
+// Set the maximum number of cached lookups, after which the least recently used lookups are evicted.
+func WithCacheMaxEntries(maxEntries int) CacheOption {
+	return func(c *Cache) {
+		c.maxEntries = maxEntries
+	}
+}
+
+// NewCache returns a new lookup cache, with a default TTL of DefaultCacheTTL and a default size of DefaultCacheMaxEntries.
+func NewCache(opts ...CacheOption) *Cache {
+	c := &Cache{
+		ttl:        DefaultCacheTTL,
+		maxEntries: DefaultCacheMaxEntries,
+		entries:    map[string]*cacheEntry{},
+		lru:        list.New(),
+		now:        time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// NewRequestCache returns a cache meant to be used for a single request only. Lookups never expire, and are not bounded in number.
+func NewRequestCache() *Cache {
+	return NewCache(WithCacheTTL(0), WithCacheMaxEntries(0))
+}
+
+// Invalidate removes all cached lookups.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = map[string]*cacheEntry{}
+	c.lru.Init()
+}
+
+// Len returns the number of cached lookups, including expired lookups that have not been evicted yet.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+func (c *Cache) get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		c.remove(entry)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(entry.element)
+	return entry.value, true
+}
+
+func (c *Cache) set(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if ok {
+		c.remove(entry)
+	}
+
+	entry = &cacheEntry{key: key, value: value}
+	if c.ttl > 0 {
+		entry.expires = c.now().Add(c.ttl)
+	}
+	entry.element = c.lru.PushFront(entry)
+	c.entries[key] = entry
+
+	for c.maxEntries > 0 && len(c.entries) > c.maxEntries {
+		oldest, _ := c.lru.Back().Value.(*cacheEntry)
+		c.remove(oldest)
+	}
+}
+
+func (c *Cache) remove(entry *cacheEntry) {
+	c.lru.Remove(entry.element)
+	delete(c.entries, entry.key)
+}
+
+// cached returns the cached result of the lookup, or runs and caches it. Without a cache, the lookup is always run.
+// Results are shared between callers, so lookups must not return values that callers are allowed to modify.
+func cached[T any](ctx context.Context, c *Cache, key []string, lookup func(context.Context) (T, error)) (T, error) {
+	if c == nil {
+		return lookup(ctx)
+	}
+
+	k := strings.Join(key, "\x00")
+	if value, ok := c.get(k); ok {
+		v, _ := value.(T)
+		return v, nil
+	}
+
+	// The first caller starts the lookup, while any concurrent callers wait for its result. The lookup is shared, so it
+	// is detached from the cancellation of the caller which started it, and every caller only stops waiting on its own.
+	ch := c.group.DoChan(k, func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedLookupTimeout)
+		defer cancel()
+
+		v, err := lookup(ctx)
+		if err != nil {
+			return v, err
+		}
+		c.set(k, v)
+		return v, nil
+	})
+
+	select {
+	case res := <-ch:
+		v, _ := res.Val.(T)
+		return v, res.Err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
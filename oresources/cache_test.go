@@ -0,0 +1,213 @@
+package oresources
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Now()
+	cache := NewCache(WithCacheTTL(time.Minute), WithCacheMaxEntries(2))
+	cache.now = func() time.Time { return now }
+
+	var calls atomic.Int32
+	lookup := func(value string) func(context.Context) (string, error) {
+		return func(context.Context) (string, error) {
+			calls.Add(1)
+			return value, nil
+		}
+	}
+
+	ctx := context.Background()
+	v, _ := cached(ctx, cache, []string{"a"}, lookup("a"))
+	v2, _ := cached(ctx, cache, []string{"a"}, lookup("b"))
+	if v != "a" || v2 != "a" || calls.Load() != 1 {
+		t.Errorf("cached lookup was not reused\ngot: %s, %s (%d calls)\nwant: a, a (1 call)", v, v2, calls.Load())
+	}
+
+	// Lookups expire after the TTL
+	now = now.Add(time.Minute)
+	v, _ = cached(ctx, cache, []string{"a"}, lookup("c"))
+	if v != "c" || calls.Load() != 2 {
+		t.Errorf("expired lookup was reused\ngot: %s (%d calls)\nwant: c (2 calls)", v, calls.Load())
+	}
+
+	// The least recently used lookup is evicted once the cache is full
+	_, _ = cached(ctx, cache, []string{"b"}, lookup("b"))
+	_, _ = cached(ctx, cache, []string{"a"}, lookup("a"))
+	_, _ = cached(ctx, cache, []string{"c"}, lookup("c"))
+	if cache.Len() != 2 {
+		t.Errorf("number of cached lookups does not match expected value\ngot: %d\nwant: %d", cache.Len(), 2)
+	}
+	if _, ok := cache.get("b"); ok {
+		t.Errorf("least recently used lookup was not evicted")
+	}
+
+	// Failed lookups are never cached
+	_, err := cached(ctx, cache, []string{"d"}, func(context.Context) (string, error) {
+		return "", errors.New("mock error")
+	})
+	if err == nil {
+		t.Errorf("lookup error was not returned")
+	}
+	if _, ok := cache.get("d"); ok {
+		t.Errorf("failed lookup was cached")
+	}
+
+	cache.Invalidate()
+	if cache.Len() != 0 {
+		t.Errorf("cache was not invalidated")
+	}
+}
+
+func TestCacheDeduplication(t *testing.T) {
+	cache := NewRequestCache()
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+
+	var calls atomic.Int32
+	lookup := func(context.Context) (string, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-unblock
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 4)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = cached(context.Background(), cache, []string{"key"}, lookup)
+		}()
+	}
+
+	<-started
+	// Give the other lookups a chance to join the one in flight
+	time.Sleep(10 * time.Millisecond)
+	close(unblock)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("concurrent lookups were not deduplicated\ngot: %d calls\nwant: 1 call", calls.Load())
+	}
+	for _, result := range results {
+		if result != "value" {
+			t.Errorf("deduplicated lookup result does not match expected value\ngot: %s\nwant: %s", result, "value")
+		}
+	}
+}
+
+func TestIAMLookupClientCache(t *testing.T) {
+	server, err := NewMockIAMLookupServer(WithUserGroups("mockuser@entur.io", []string{"team-a"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := NewRequestCache()
+	client := server.Client().WithCache(cache)
+
+	groups, err := client.EntraIDUserGroups(context.Background(), "mockuser@entur.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Modifying a returned result should not affect the cached result
+	groups[0] = "modified"
+
+	groups, err = client.EntraIDUserGroups(context.Background(), "mockuser@entur.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(groups, []string{"team-a"}) {
+		t.Errorf("cached groups do not match expected value\ngot: %v\nwant: %v", groups, []string{"team-a"})
+	}
+	if cache.Len() != 1 {
+		t.Errorf("number of cached lookups does not match expected value\ngot: %d\nwant: %d", cache.Len(), 1)
+	}
+}
+
+func TestCacheCancelledLookup(t *testing.T) {
+	cache := NewRequestCache()
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+
+	lookup := func(ctx context.Context) (string, error) {
+		close(started)
+		select {
+		case <-unblock:
+			return "value", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+
+	// The caller starting the lookup gives up, while another caller is still waiting for its result
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := cached(ctx, cache, []string{"key"}, lookup)
+		first <- err
+	}()
+	<-started
+
+	second := make(chan string)
+	go func() {
+		v, _ := cached(context.Background(), cache, []string{"key"}, lookup)
+		second <- v
+	}()
+	// Give the second lookup a chance to join the one in flight
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled lookup error does not match expected value\ngot: %v\nwant: %s", err, context.Canceled)
+	}
+
+	close(unblock)
+	if v := <-second; v != "value" {
+		t.Errorf("waiting lookup result does not match expected value\ngot: %q\nwant: %q", v, "value")
+	}
+}
+
+func TestIAMLookupClientSharedCache(t *testing.T) {
+	a, err := NewMockIAMLookupServer(WithUserGroups("mockuser@entur.io", []string{"team-a"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewMockIAMLookupServer(WithUserGroups("mockuser@entur.io", []string{"team-b"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clients for different resources can share a cache, without mixing up their results
+	cache := NewCache()
+	clientA := NewIAMLookupClientWithHTTPClient("http://iam-a.entur.io", &http.Client{Transport: HandlerTransport(a.Handler())}).WithCache(cache)
+	clientB := NewIAMLookupClientWithHTTPClient("http://iam-b.entur.io", &http.Client{Transport: HandlerTransport(b.Handler())}).WithCache(cache)
+
+	for _, test := range []struct {
+		client *IAMLookupClient
+		want   []string
+	}{
+		{client: clientA, want: []string{"team-a"}},
+		{client: clientB, want: []string{"team-b"}},
+		{client: clientA, want: []string{"team-a"}},
+	} {
+		groups, err := test.client.EntraIDUserGroups(context.Background(), "mockuser@entur.io")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(groups, test.want) {
+			t.Errorf("cached groups do not match expected value\ngot: %v\nwant: %v", groups, test.want)
+		}
+	}
+	if cache.Len() != 2 {
+		t.Errorf("number of cached lookups does not match expected value\ngot: %d\nwant: %d", cache.Len(), 2)
+	}
+}
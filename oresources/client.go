@@ -0,0 +1,131 @@
+package oresources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/idtoken"
+)
+
+// -----------------------
+// JSON Client
+// -----------------------
+
+const defaultTimeout = 10 * time.Second
+const defaultDialerTimeout = 5 * time.Second
+
+type ClientOption = idtoken.ClientOption
+
+// The JSONClient type is a generic client for resources with a JSON API. It authenticates with an id token when
+// credentials are available, times out requests, turns non-2xx responses into a StatusError, and retries and fails
+// fast according to its RetryPolicy and CircuitBreaker. Specific resource clients are meant to be built on top of it.
+type JSONClient struct {
+	client  *http.Client
+	url     string
+	retry   RetryPolicy
+	breaker *CircuitBreaker
+}
+
+// NewJSONClient returns a client for the resource at the given URL, with a default timeout of 10 seconds
+// and the DefaultRetryPolicy. Requests are authenticated according to AuthDiscover, see NewJSONClientWithAuth.
+func NewJSONClient(ctx context.Context, url string, opts ...ClientOption) (*JSONClient, error) {
+	return NewJSONClientWithAuth(ctx, url, AuthDiscover(opts...))
+}
+
+// NewJSONClientWithAuth returns a client for the resource at the given URL, which authenticates its requests with auth.
+func NewJSONClientWithAuth(ctx context.Context, url string, auth ClientAuth) (*JSONClient, error) {
+	return newJSONClient(ctx, fmt.Sprintf("'%s'", url), url, auth)
+}
+
+func newJSONClient(ctx context.Context, name string, url string, auth ClientAuth) (*JSONClient, error) {
+	if auth == nil {
+		auth = AuthDiscover()
+	}
+
+	client, err := auth.httpClient(ctx, name, url)
+	if err != nil {
+		return nil, err
+	}
+	if client.Timeout == 0 {
+		client.Timeout = defaultTimeout
+	}
+
+	return &JSONClient{
+		client: client,
+		url:    url,
+		retry:  DefaultRetryPolicy,
+	}, nil
+}
+
+// NewJSONClientWithHTTPClient returns a client for the resource at the given URL, which sends all requests through the
+// given http client instead of authenticating with an id token, e.g. one using HandlerTransport in tests.
+func NewJSONClientWithHTTPClient(url string, client *http.Client) *JSONClient {
+	cp := *client
+	if cp.Timeout == 0 {
+		cp.Timeout = defaultTimeout
+	}
+
+	return &JSONClient{
+		client: &cp,
+		url:    url,
+		retry:  DefaultRetryPolicy,
+	}
+}
+
+// URL returns the base URL of the resource.
+func (c *JSONClient) URL() string {
+	return c.url
+}
+
+// WithTimeout returns a copy of the client with the given timeout per attempt.
+func (c *JSONClient) WithTimeout(timeout time.Duration) *JSONClient {
+	cp := *c
+	client := *c.client
+	client.Timeout = timeout
+	cp.client = &client
+	return &cp
+}
+
+// WithRetryPolicy returns a copy of the client which retries requests failing with ErrUnavailable according to the policy.
+// By default, clients use DefaultRetryPolicy.
+func (c *JSONClient) WithRetryPolicy(policy RetryPolicy) *JSONClient {
+	cp := *c
+	cp.retry = policy
+	return &cp
+}
+
+// WithCircuitBreaker returns a copy of the client which fails fast with ErrCircuitOpen while the breaker is open, see CircuitBreaker.
+func (c *JSONClient) WithCircuitBreaker(breaker *CircuitBreaker) *JSONClient {
+	cp := *c
+	cp.breaker = breaker
+	return &cp
+}
+
+// Post sends reqBody as JSON to the given path of the resource, and decodes the JSON response into resBody.
+func (c *JSONClient) Post(ctx context.Context, path string, reqBody any, resBody any) error {
+	return c.Do(ctx, http.MethodPost, path, reqBody, resBody)
+}
+
+// Do sends reqBody as JSON to the given path of the resource with the given method, and decodes the JSON response into resBody.
+// Requests failing with ErrUnavailable are retried, and recorded in the circuit breaker if configured.
+func (c *JSONClient) Do(ctx context.Context, method string, path string, reqBody any, resBody any) error {
+	if c.breaker != nil {
+		err := c.breaker.allow()
+		if err != nil {
+			return fmt.Errorf("resource at '%s' is not called: %w", c.url, err)
+		}
+	}
+
+	url := fmt.Sprintf("%s%s", c.url, path)
+	err := retry(ctx, c.retry, func() error {
+		_, err := request(ctx, c.client, method, url, nil, reqBody, resBody)
+		return err
+	})
+
+	if c.breaker != nil {
+		c.breaker.record(err)
+	}
+	return err
+}
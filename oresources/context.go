@@ -0,0 +1,240 @@
+package oresources
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/entur/go-logging"
+	"golang.org/x/sync/singleflight"
+)
+
+// -----------------------
+// Client Pools
+// -----------------------
+
+const DefaultMaxPoolClients = 32 // Default maximum number of clients kept by a pool.
+
+type poolEntry[K comparable, V any] struct {
+	key    K
+	client V
+}
+
+// pool keeps a bounded number of clients, evicting the least recently used ones. Clients are created outside of the
+// lock, so that creating a slow client does not block requests to other resources, and only once at a time per key.
+type pool[K comparable, V any] struct {
+	mu      sync.Mutex
+	max     int
+	entries map[K]*list.Element
+	lru     *list.List // Most recently used first
+	group   singleflight.Group
+}
+
+func newPool[K comparable, V any]() *pool[K, V] {
+	return &pool[K, V]{
+		max:     DefaultMaxPoolClients,
+		entries: map[K]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+func (p *pool[K, V]) get(key K, group string, create func() (V, error)) (V, error) {
+	p.mu.Lock()
+	e, ok := p.entries[key]
+	if ok {
+		p.lru.MoveToFront(e)
+		entry, _ := e.Value.(*poolEntry[K, V])
+		p.mu.Unlock()
+		return entry.client, nil
+	}
+	p.mu.Unlock()
+
+	client, err, _ := p.group.Do(group, func() (any, error) {
+		// The client may have been added since the lookup above
+		p.mu.Lock()
+		e, ok := p.entries[key]
+		p.mu.Unlock()
+		if ok {
+			entry, _ := e.Value.(*poolEntry[K, V])
+			return entry.client, nil
+		}
+
+		client, err := create()
+		if err != nil {
+			return nil, err
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.entries[key] = p.lru.PushFront(&poolEntry[K, V]{key: key, client: client})
+		p.evict()
+		return client, nil
+	})
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+
+	value, _ := client.(V)
+	return value, nil
+}
+
+func (p *pool[K, V]) setMax(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.max = n
+	p.evict()
+}
+
+func (p *pool[K, V]) evict() {
+	for p.max > 0 && p.lru.Len() > p.max {
+		e := p.lru.Back()
+		entry, _ := e.Value.(*poolEntry[K, V])
+		p.lru.Remove(e)
+		delete(p.entries, entry.key)
+	}
+}
+
+// The IAMLookupPool type creates an IAMLookupClient once per URL, and reuses it for all later requests to the same URL.
+// At most DefaultMaxPoolClients clients are kept, see SetMaxClients.
+type IAMLookupPool struct {
+	auth    ClientAuth
+	clients *pool[string, *IAMLookupClient]
+}
+
+// NewIAMLookupPool returns a new pool, creating its clients with the given options, see NewIAMLookupClient.
+func NewIAMLookupPool(opts ...IAMClientOption) *IAMLookupPool {
+	return NewIAMLookupPoolWithAuth(AuthDiscover(opts...))
+}
+
+// NewIAMLookupPoolWithAuth returns a new pool, creating clients which authenticate their requests with auth.
+func NewIAMLookupPoolWithAuth(auth ClientAuth) *IAMLookupPool {
+	return &IAMLookupPool{
+		auth:    auth,
+		clients: newPool[string, *IAMLookupClient](),
+	}
+}
+
+// SetMaxClients sets the maximum number of clients kept by the pool, evicting the least recently used ones.
+// Zero or less means no limit.
+func (p *IAMLookupPool) SetMaxClients(n int) {
+	p.clients.setMax(n)
+}
+
+// Client returns the client for the given URL, creating it if necessary. Failures to create a client are not remembered.
+func (p *IAMLookupPool) Client(ctx context.Context, url string) (*IAMLookupClient, error) {
+	return p.clients.get(url, url, func() (*IAMLookupClient, error) {
+		// The client outlives the request it was created for
+		return NewIAMLookupClientWithAuth(context.WithoutCancel(ctx), url, p.auth)
+	})
+}
+
+// ClientFactory creates the client for a resource, given its URL. See NewJSONClient for a generic client to build on.
+type ClientFactory func(ctx context.Context, url string) (any, error)
+
+type clientPoolKey struct {
+	name string
+	url  string
+}
+
+// The ClientPool type creates a client once per resource name and URL, and reuses it for all later requests.
+// At most DefaultMaxPoolClients clients are kept, see SetMaxClients.
+type ClientPool struct {
+	clients *pool[clientPoolKey, any]
+}
+
+// NewClientPool returns a new, empty pool.
+func NewClientPool() *ClientPool {
+	return &ClientPool{
+		clients: newPool[clientPoolKey, any](),
+	}
+}
+
+// SetMaxClients sets the maximum number of clients kept by the pool, evicting the least recently used ones.
+// Zero or less means no limit.
+func (p *ClientPool) SetMaxClients(n int) {
+	p.clients.setMax(n)
+}
+
+// Client returns the client for the given resource name and URL, creating it with the factory if necessary.
+// Failures to create a client are not remembered.
+func (p *ClientPool) Client(ctx context.Context, name string, url string, factory ClientFactory) (any, error) {
+	key := clientPoolKey{name: name, url: url}
+	return p.clients.get(key, name+"\x00"+url, func() (any, error) {
+		// The client outlives the request it was created for
+		return factory(context.WithoutCancel(ctx), url)
+	})
+}
+
+// -----------------------
+// Context
+// -----------------------
+
+// Name of the IAM Lookup resource in requests.
+const ResourceIAMLookup = "iamLookup"
+
+type clientCtxKey struct {
+	name string
+}
+
+// lazyClient creates a client the first time it is retrieved from the context, and reuses it for the rest of the request.
+type lazyClient struct {
+	name   string
+	create func(ctx context.Context) (any, error)
+	once   sync.Once
+	client any
+}
+
+func (l *lazyClient) get(ctx context.Context) any {
+	l.once.Do(func() {
+		client, err := l.create(ctx)
+		if err != nil {
+			logging.Ctx(ctx).Warn().Err(err).Msgf("Unable to create the client for resource '%s', it will not be available through the context", l.name)
+			return
+		}
+		l.client = client
+	})
+	return l.client
+}
+
+// Retrieve the client for the named resource attached to the context. When handling requests, the SDK attaches a client
+// for every resource in the request with a registered ClientFactory, which is created the first time it is retrieved.
+// The second return value is false if no client of type T is attached, or it could not be created.
+func Client[T any](ctx context.Context, name string) (T, bool) {
+	value := ctx.Value(clientCtxKey{name: name})
+	if lazy, ok := value.(*lazyClient); ok {
+		value = lazy.get(ctx)
+	}
+
+	client, ok := value.(T)
+	return client, ok
+}
+
+// HasClient reports whether a client for the named resource is attached to the context, without creating it.
+func HasClient(ctx context.Context, name string) bool {
+	return ctx.Value(clientCtxKey{name: name}) != nil
+}
+
+// Attach a client for the named resource to the given context, making it available through Client.
+func WithClient(ctx context.Context, name string, client any) context.Context {
+	return context.WithValue(ctx, clientCtxKey{name: name}, client)
+}
+
+// Attach a client for the named resource to the given context, which is created by create the first time it is
+// retrieved through Client. Failures to create the client are logged, and the client is then unavailable.
+func WithLazyClient(ctx context.Context, name string, create func(ctx context.Context) (any, error)) context.Context {
+	return context.WithValue(ctx, clientCtxKey{name: name}, &lazyClient{name: name, create: create})
+}
+
+// Retrieve the IAM Lookup client attached to the context. When handling requests, the SDK attaches a client for the
+// URL in req.Resources.IAMLookup.URL. The second return value is false if no client is attached, e.g. if the request
+// has no IAM Lookup URL.
+func IAMLookup(ctx context.Context) (*IAMLookupClient, bool) {
+	client, ok := Client[*IAMLookupClient](ctx, ResourceIAMLookup)
+	return client, ok && client != nil
+}
+
+// Attach an IAM Lookup client to the given context, making it available through IAMLookup.
+func WithIAMLookup(ctx context.Context, client *IAMLookupClient) context.Context {
+	return WithClient(ctx, ResourceIAMLookup, client)
+}
@@ -0,0 +1,133 @@
+package oresources
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testResourceClient struct {
+	url string
+}
+
+func TestClientPool(t *testing.T) {
+	var created atomic.Int32
+	factory := func(_ context.Context, url string) (any, error) {
+		created.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return &testResourceClient{url: url}, nil
+	}
+
+	pool := NewClientPool()
+	pool.SetMaxClients(2)
+	ctx := context.Background()
+
+	// Concurrent requests for the same resource share a single client
+	var wg sync.WaitGroup
+	clients := make([]any, 4)
+	for i := range clients {
+		wg.Go(func() {
+			clients[i], _ = pool.Client(ctx, "costs", "http://a", factory)
+		})
+	}
+	wg.Wait()
+	if created.Load() != 1 {
+		t.Errorf("number of created clients does not match expected value\ngot: %d\nwant: %d", created.Load(), 1)
+	}
+	for _, client := range clients {
+		if client != clients[0] {
+			t.Errorf("concurrent requests did not share the client\ngot: %v\nwant: %v", client, clients[0])
+		}
+	}
+
+	// The least recently used client is evicted once the pool is full
+	_, _ = pool.Client(ctx, "costs", "http://b", factory)
+	_, _ = pool.Client(ctx, "costs", "http://a", factory)
+	_, _ = pool.Client(ctx, "costs", "http://c", factory)
+	if created.Load() != 3 {
+		t.Errorf("number of created clients does not match expected value\ngot: %d\nwant: %d", created.Load(), 3)
+	}
+	client, _ := pool.Client(ctx, "costs", "http://a", factory)
+	if client != clients[0] {
+		t.Errorf("recently used client was evicted")
+	}
+	_, _ = pool.Client(ctx, "costs", "http://b", factory)
+	if created.Load() != 4 {
+		t.Errorf("least recently used client was not evicted\ngot: %d created\nwant: %d created", created.Load(), 4)
+	}
+
+	// Failures are not remembered
+	failing := func(_ context.Context, _ string) (any, error) {
+		created.Add(1)
+		return nil, errors.New("mock error")
+	}
+	for range 2 {
+		_, err := pool.Client(ctx, "failing", "http://a", failing)
+		if err == nil {
+			t.Errorf("failing factory did not return an error")
+		}
+	}
+	if created.Load() != 6 {
+		t.Errorf("failure to create a client was remembered\ngot: %d created\nwant: %d created", created.Load(), 6)
+	}
+}
+
+func TestClientPoolSlowFactory(t *testing.T) {
+	pool := NewClientPool()
+	unblock := make(chan struct{})
+	started := make(chan struct{})
+
+	go func() {
+		_, _ = pool.Client(context.Background(), "slow", "http://a", func(_ context.Context, url string) (any, error) {
+			close(started)
+			<-unblock
+			return &testResourceClient{url: url}, nil
+		})
+	}()
+	<-started
+	defer close(unblock)
+
+	// Creating a slow client does not block clients for other resources
+	done := make(chan struct{})
+	go func() {
+		_, _ = pool.Client(context.Background(), "fast", "http://b", func(_ context.Context, url string) (any, error) {
+			return &testResourceClient{url: url}, nil
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("creating a client was blocked by a slow client for another resource")
+	}
+}
+
+func TestLazyClient(t *testing.T) {
+	var created int
+	ctx := WithLazyClient(context.Background(), "costs", func(_ context.Context) (any, error) {
+		created++
+		return &testResourceClient{url: "http://a"}, nil
+	})
+	ctx = WithLazyClient(ctx, "failing", func(_ context.Context) (any, error) {
+		return nil, errors.New("mock error")
+	})
+
+	if !HasClient(ctx, "costs") || created != 0 {
+		t.Fatalf("lazy client was created before it was retrieved")
+	}
+
+	a, ok := Client[*testResourceClient](ctx, "costs")
+	b, _ := Client[*testResourceClient](ctx, "costs")
+	if !ok || a == nil || a != b || created != 1 {
+		t.Errorf("lazy client was not created once\ngot: %d created, %v, %v", created, a, b)
+	}
+
+	_, ok = Client[any](ctx, "failing")
+	if ok {
+		t.Errorf("client which failed to be created was available")
+	}
+}
@@ -0,0 +1,165 @@
+package oresources
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// iamContract describes the request sent to, and the response received from, every IAM Lookup endpoint, as written out
+// by hand rather than produced by either side. The client and the mock server are each tested against it on their own,
+// so that they cannot drift away from the IAM Lookup API together. Keep it in sync with the IAM Lookup API.
+var iamContract = []struct {
+	title    string
+	endpoint string
+	request  string
+	response string
+	seed     MockIAMServerOption
+	call     func(context.Context, *IAMLookupClient) (any, error)
+	result   any
+}{
+	{
+		title:    "GCP app projects",
+		endpoint: "/app/projects/gcp",
+		request:  `{"appId": "mockapp"}`,
+		response: `{"projects": ["ent-mockapp-dev", "ent-mockapp-prd"]}`,
+		seed:     WithAppIDProjects("mockapp", []string{"ent-mockapp-dev", "ent-mockapp-prd"}),
+		call: func(ctx context.Context, iam *IAMLookupClient) (any, error) {
+			return iam.GCPAppProjectIDs(ctx, "mockapp")
+		},
+		result: []string{"ent-mockapp-dev", "ent-mockapp-prd"},
+	},
+	{
+		title:    "GCP user access",
+		endpoint: "/access/gcp",
+		request:  `{"user": "mockuser@entur.io", "role": "mockrole", "resource": "projects/ent-mockapp-dev"}`,
+		response: `{"access": true}`,
+		seed:     WithUserProjectRoles("mockuser@entur.io", "ent-mockapp-dev", []string{"mockrole"}),
+		call: func(ctx context.Context, iam *IAMLookupClient) (any, error) {
+			return iam.GCPUserHasRoleInProjects(ctx, "mockuser@entur.io", "mockrole", "ent-mockapp-dev")
+		},
+		result: true,
+	},
+	{
+		title:    "GCP user roles",
+		endpoint: "/roles/gcp",
+		request:  `{"user": "mockuser@entur.io", "resource": "projects/ent-mockapp-dev"}`,
+		response: `{"roles": ["mockrole"]}`,
+		seed:     WithUserProjectRoles("mockuser@entur.io", "ent-mockapp-dev", []string{"mockrole"}),
+		call: func(ctx context.Context, iam *IAMLookupClient) (any, error) {
+			return iam.GCPUserRolesInProject(ctx, "mockuser@entur.io", "ent-mockapp-dev")
+		},
+		result: []string{"mockrole"},
+	},
+	{
+		title:    "Entra ID user groups",
+		endpoint: "/groups/entraid",
+		request:  `{"user": "mockuser@entur.io"}`,
+		response: `{"groups": ["team-a"]}`,
+		seed:     WithUserGroups("mockuser@entur.io", []string{"team-a"}),
+		call: func(ctx context.Context, iam *IAMLookupClient) (any, error) {
+			return iam.EntraIDUserGroups(ctx, "mockuser@entur.io")
+		},
+		result: []string{"team-a"},
+	},
+	{
+		title:    "Entra ID user in group",
+		endpoint: "/groups/entraid/member",
+		request:  `{"user": "mockuser@entur.io", "group": "team-a"}`,
+		response: `{"member": true}`,
+		seed:     WithUserGroups("mockuser@entur.io", []string{"team-a"}),
+		call: func(ctx context.Context, iam *IAMLookupClient) (any, error) {
+			return iam.EntraIDUserInGroup(ctx, "mockuser@entur.io", "team-a")
+		},
+		result: true,
+	},
+	{
+		title:    "App owners",
+		endpoint: "/app/owners",
+		request:  `{"appId": "mockapp"}`,
+		response: `{"owners": ["mockowner@entur.io"]}`,
+		seed:     WithAppOwners("mockapp", []string{"mockowner@entur.io"}),
+		call: func(ctx context.Context, iam *IAMLookupClient) (any, error) {
+			return iam.AppOwners(ctx, "mockapp")
+		},
+		result: []string{"mockowner@entur.io"},
+	},
+}
+
+// jsonEqual reports whether both documents decode to the same value, regardless of formatting and key order.
+func jsonEqual(t *testing.T, a []byte, b []byte) bool {
+	t.Helper()
+
+	var va, vb any
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatalf("unable to decode '%s': %s", a, err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatalf("unable to decode '%s': %s", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestIAMLookupClientContract(t *testing.T) {
+	for _, test := range iamContract {
+		tmp := test
+		t.Run(tmp.title, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				body, _ := io.ReadAll(req.Body)
+				if req.Method != http.MethodPost || req.URL.Path != tmp.endpoint {
+					t.Errorf("request does not match the contract\ngot: %s %s\nwant: %s %s", req.Method, req.URL.Path, http.MethodPost, tmp.endpoint)
+				}
+				if !jsonEqual(t, body, []byte(tmp.request)) {
+					t.Errorf("request body does not match the contract\ngot: %s\nwant: %s", body, tmp.request)
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(tmp.response))
+			}))
+			t.Cleanup(server.Close)
+
+			client, err := NewIAMLookupClientWithAuth(context.Background(), server.URL, AuthNone())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := tmp.call(context.Background(), client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result, tmp.result) {
+				t.Errorf("lookup result does not match expected value\ngot: %v\nwant: %v", result, tmp.result)
+			}
+		})
+	}
+}
+
+func TestMockIAMLookupServerContract(t *testing.T) {
+	for _, test := range iamContract {
+		tmp := test
+		t.Run(tmp.title, func(t *testing.T) {
+			t.Parallel()
+
+			server, err := NewMockIAMLookupServer(tmp.seed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tmp.endpoint, bytes.NewBufferString(tmp.request)))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("response status does not match expected value\ngot: %d\nwant: %d", rec.Code, http.StatusOK)
+			}
+			if !jsonEqual(t, rec.Body.Bytes(), []byte(tmp.response)) {
+				t.Errorf("response body does not match the contract\ngot: %s\nwant: %s", rec.Body.String(), tmp.response)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package oresources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/entur/go-logging"
+)
+
+// -----------------------
+// Errors
+// -----------------------
+
+var (
+	ErrNotFound         = errors.New("not found")               // The resource responded with 404
+	ErrUnauthorized     = errors.New("unauthorized")            // The resource responded with 401 or 403
+	ErrUnavailable      = errors.New("unavailable")             // The resource responded with 429 or 5xx, or could not be reached
+	ErrUnexpectedStatus = errors.New("unexpected status")       // The resource responded with any other non-2xx status
+	ErrCircuitOpen      = errors.New("circuit breaker is open") // The resource has failed too many times in a row, and is not called
+)
+
+const maxErrorBodySize = 4096 // Maximum number of bytes of a response body kept in a StatusError
+
+// The StatusError type represents a non-2xx response from a resource.
+// It wraps one of ErrNotFound, ErrUnauthorized, ErrUnavailable or ErrUnexpectedStatus, depending on the status code.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http '%s' request to '%s' failed with status %d (%s): %s", e.Method, e.URL, e.StatusCode, e.Unwrap(), e.Body)
+}
+
+func (e *StatusError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError:
+		return ErrUnavailable
+	default:
+		return ErrUnexpectedStatus
+	}
+}
+
+// -----------------------
+// Retries
+// -----------------------
+
+// The RetryPolicy type describes how lookups failing with ErrUnavailable are retried, using exponential backoff.
+type RetryPolicy struct {
+	MaxAttempts    int           // Total number of attempts, including the first one
+	InitialBackoff time.Duration // Backoff before the first retry, doubled for every later retry
+	MaxBackoff     time.Duration // Upper bound of the backoff between retries
+}
+
+// Default retry policy used by resource clients.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+}
+
+// NoRetries disables retries.
+var NoRetries = RetryPolicy{MaxAttempts: 1}
+
+func retry(ctx context.Context, policy RetryPolicy, fn func() error) error {
+	backoff := policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= policy.MaxAttempts || !errors.Is(err, ErrUnavailable) {
+			return err
+		}
+
+		logging.Ctx(ctx).Debug().Err(err).Int("gorch_resource_attempt", attempt).Msgf("Resource is unavailable, retrying in %s", backoff)
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if policy.MaxBackoff > 0 {
+			backoff = min(backoff, policy.MaxBackoff)
+		}
+	}
+}
+
+// -----------------------
+// Circuit Breaker
+// -----------------------
+
+const DefaultCircuitBreakerThreshold = 5               // Default number of consecutive failures before the circuit breaker opens.
+const DefaultCircuitBreakerCooldown = 30 * time.Second // Default time the circuit breaker stays open before a new attempt is allowed.
+
+// The CircuitBreaker type stops calling a resource after too many consecutive lookups have failed with ErrUnavailable,
+// so that callers fail fast instead of waiting for timeouts and retries. Once the cooldown has passed, a single lookup
+// is let through: if it succeeds the breaker closes again, otherwise it stays open for another cooldown.
+//
+// A breaker is attached to a client with WithCircuitBreaker. The SDK reuses its clients for every request to the same URL,
+// so a breaker attached to such a client protects all requests to that resource. Clients created separately for the same
+// resource should share a single breaker.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int       // Number of consecutive failures
+	openedAt  time.Time // When the breaker was last opened, zero if it is closed
+	probing   bool      // If a lookup has been let through after the cooldown
+	now       func() time.Time
+}
+
+// NewCircuitBreaker returns a circuit breaker which opens after threshold consecutive failures, for the given cooldown.
+// A threshold or cooldown of zero or less uses the default.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = DefaultCircuitBreakerThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultCircuitBreakerCooldown
+	}
+
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Open reports whether the breaker is currently rejecting lookups.
+func (cb *CircuitBreaker) Open() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return !cb.openedAt.IsZero() && (cb.probing || cb.now().Sub(cb.openedAt) < cb.cooldown)
+}
+
+// Reset closes the breaker, and forgets all failures.
+func (cb *CircuitBreaker) Reset() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures = 0
+	cb.openedAt = time.Time{}
+	cb.probing = false
+}
+
+func (cb *CircuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.openedAt.IsZero() {
+		return nil
+	}
+
+	remaining := cb.cooldown - cb.now().Sub(cb.openedAt)
+	if remaining <= 0 && !cb.probing {
+		cb.probing = true
+		return nil
+	}
+
+	return fmt.Errorf("%w: %w after %d consecutive failures, the next attempt is allowed in %s", ErrUnavailable, ErrCircuitOpen, cb.failures, max(remaining, 0).Round(time.Second))
+}
+
+func (cb *CircuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	var statusErr *StatusError
+	switch {
+	case errors.Is(err, ErrUnavailable):
+	case err == nil || errors.As(err, &statusErr):
+		// The resource responded, even if it was with an error
+		cb.failures = 0
+		cb.openedAt = time.Time{}
+		cb.probing = false
+		return
+	default:
+		// The lookup failed for reasons unrelated to the resource, e.g. a canceled context, so let another lookup through
+		cb.probing = false
+		return
+	}
+
+	cb.failures++
+	if cb.probing || cb.failures >= cb.threshold {
+		cb.openedAt = cb.now()
+		cb.probing = false
+	}
+}
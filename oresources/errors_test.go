@@ -0,0 +1,175 @@
+package oresources
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     time.Millisecond,
+}
+
+// newHandlerClient returns a client which calls the handler in-memory.
+func newHandlerClient(handler http.Handler) *IAMLookupClient {
+	return NewIAMLookupClientWithHTTPClient("http://mock", &http.Client{Transport: HandlerTransport(handler)})
+}
+
+// newStatusClient returns a client for a resource responding with the given statuses in order, and then with 200 and a group list.
+func newStatusClient(statuses ...int) (*IAMLookupClient, *atomic.Int32) {
+	var calls atomic.Int32
+	client := newHandlerClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(calls.Add(1))
+		if call <= len(statuses) {
+			http.Error(w, "mock error", statuses[call-1])
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"groups": ["team-a"]}`))
+	}))
+
+	return client, &calls
+}
+
+func TestIAMLookupClientErrors(t *testing.T) {
+	tests := []struct {
+		title    string
+		statuses []int
+		want     error
+		calls    int32
+	}{
+		{
+			title: "Success",
+			calls: 1,
+		},
+		{
+			title:    "Retried until success",
+			statuses: []int{http.StatusInternalServerError, http.StatusTooManyRequests},
+			calls:    3,
+		},
+		{
+			title:    "Retried until unavailable",
+			statuses: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway},
+			want:     ErrUnavailable,
+			calls:    3,
+		},
+		{
+			title:    "Not found",
+			statuses: []int{http.StatusNotFound},
+			want:     ErrNotFound,
+			calls:    1,
+		},
+		{
+			title:    "Unauthorized",
+			statuses: []int{http.StatusForbidden},
+			want:     ErrUnauthorized,
+			calls:    1,
+		},
+		{
+			title:    "Unexpected status",
+			statuses: []int{http.StatusBadRequest},
+			want:     ErrUnexpectedStatus,
+			calls:    1,
+		},
+	}
+
+	for _, test := range tests {
+		tmp := test
+		t.Run(tmp.title, func(t *testing.T) {
+			t.Parallel()
+
+			client, calls := newStatusClient(tmp.statuses...)
+			client = client.WithRetryPolicy(testRetryPolicy)
+
+			groups, err := client.EntraIDUserGroups(context.Background(), "mockuser@entur.io")
+			if !errors.Is(err, tmp.want) {
+				t.Errorf("lookup error does not match expected value\ngot: %v\nwant: %v", err, tmp.want)
+			}
+			if calls.Load() != tmp.calls {
+				t.Errorf("number of requests does not match expected value\ngot: %d\nwant: %d", calls.Load(), tmp.calls)
+			}
+
+			if tmp.want == nil {
+				if len(groups) != 1 {
+					t.Errorf("groups do not match expected value\ngot: %v\nwant: %v", groups, []string{"team-a"})
+				}
+				return
+			}
+
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("lookup error is not a StatusError: %v", err)
+			}
+			if statusErr.StatusCode != tmp.statuses[len(tmp.statuses)-1] || statusErr.Body != "mock error" {
+				t.Errorf("status error does not match expected value\ngot: %d %s\nwant: %d %s", statusErr.StatusCode, statusErr.Body, tmp.statuses[len(tmp.statuses)-1], "mock error")
+			}
+		})
+	}
+}
+
+func TestIAMLookupClientUnknownApp(t *testing.T) {
+	client, _ := newStatusClient(http.StatusNotFound)
+
+	projectIDs, err := client.GCPAppProjectIDs(context.Background(), "unknown")
+	if err != nil || projectIDs != nil {
+		t.Errorf("unknown app should have no projects\ngot: %v, %v\nwant: [], <nil>", projectIDs, err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	client, calls := newStatusClient(
+		http.StatusServiceUnavailable, http.StatusServiceUnavailable,
+		http.StatusServiceUnavailable, // The probe after the first cooldown
+	)
+
+	now := time.Now()
+	breaker := NewCircuitBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	client = client.WithRetryPolicy(NoRetries).WithCircuitBreaker(breaker)
+
+	lookup := func() error {
+		_, err := client.EntraIDUserGroups(context.Background(), "mockuser@entur.io")
+		return err
+	}
+
+	_ = lookup()
+	_ = lookup()
+	if !breaker.Open() {
+		t.Fatalf("circuit breaker did not open after 2 consecutive failures")
+	}
+
+	err := lookup()
+	if !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, ErrUnavailable) {
+		t.Errorf("lookup error does not match expected value\ngot: %v\nwant: %v", err, ErrCircuitOpen)
+	}
+	if err != nil && !strings.Contains(err.Error(), "next attempt is allowed in 1m0s") {
+		t.Errorf("lookup error does not say when the next attempt is allowed: %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("open circuit breaker did not fail fast\ngot: %d requests\nwant: %d requests", calls.Load(), 2)
+	}
+
+	// A failing probe keeps the breaker open for another cooldown
+	now = now.Add(time.Minute)
+	err = lookup()
+	if !errors.Is(err, ErrUnavailable) || errors.Is(err, ErrCircuitOpen) || !breaker.Open() {
+		t.Errorf("failing probe did not keep the circuit breaker open: %v", err)
+	}
+
+	// A successful probe closes the breaker
+	now = now.Add(time.Minute)
+	err = lookup()
+	if err != nil || breaker.Open() {
+		t.Errorf("successful probe did not close the circuit breaker: %v", err)
+	}
+	if calls.Load() != 4 {
+		t.Errorf("number of requests does not match expected value\ngot: %d\nwant: %d", calls.Load(), 4)
+	}
+}
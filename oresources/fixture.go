@@ -0,0 +1,171 @@
+package oresources
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------
+// Fixtures
+// -----------------------
+
+const DefaultFixturePollInterval = time.Second // Default interval at which a fixture file is checked for changes.
+
+// The MockIAMFixture type describes all of the data served by a MockIAMLookupServer. It can be written in YAML or JSON:
+//
+//	apps:
+//	  myapp:
+//	    projects: [ent-myapp-dev, ent-myapp-prd]
+//	    owners: [owner@entur.io]
+//	users:
+//	  user@entur.io:
+//	    groups: [team-a]
+//	    roles:
+//	      ent-myapp-dev: [your_so_role]
+type MockIAMFixture struct {
+	Apps  map[string]MockIAMFixtureApp  `yaml:"apps" json:"apps"`
+	Users map[string]MockIAMFixtureUser `yaml:"users" json:"users"`
+}
+
+type MockIAMFixtureApp struct {
+	Projects []string `yaml:"projects" json:"projects"`
+	Owners   []string `yaml:"owners" json:"owners"`
+}
+
+type MockIAMFixtureUser struct {
+	Groups []string            `yaml:"groups" json:"groups"`
+	Roles  map[string][]string `yaml:"roles" json:"roles"` // Roles by GCP project id
+}
+
+// ReadMockIAMFixture reads a YAML or JSON fixture file.
+func ReadMockIAMFixture(path string) (MockIAMFixture, error) {
+	var fixture MockIAMFixture
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fixture, fmt.Errorf("unable to read fixture: %w", err)
+	}
+
+	err = yaml.Unmarshal(data, &fixture)
+	if err != nil {
+		return fixture, fmt.Errorf("unable to unmarshal fixture '%s': %w", path, err)
+	}
+
+	return fixture, nil
+}
+
+// LoadFixture replaces all of the apps, users, groups and roles served by the server with those in the fixture.
+func (s *MockIAMLookupServer) LoadFixture(fixture MockIAMFixture) {
+	appIDProjects := map[string][]string{}
+	appOwners := map[string][]string{}
+	for appID, app := range fixture.Apps {
+		appIDProjects[appID] = app.Projects
+		appOwners[appID] = app.Owners
+	}
+
+	userGroups := map[string][]string{}
+	userProjectRoles := map[string]map[string][]string{}
+	for email, user := range fixture.Users {
+		userGroups[email] = user.Groups
+		for projectID, roles := range user.Roles {
+			project, ok := userProjectRoles[projectID]
+			if !ok {
+				project = map[string][]string{}
+				userProjectRoles[projectID] = project
+			}
+			project[email] = roles
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.appIDProjects = appIDProjects
+	s.appOwners = appOwners
+	s.userGroups = userGroups
+	s.userProjectRoles = userProjectRoles
+}
+
+// Load the server data from a YAML or JSON fixture file (see MockIAMFixture), and reload it whenever the file changes
+// while the server is running. The fixture replaces any data set by other options. If a changed file cannot be loaded,
+// the previous data is kept.
+func WithFixtureFile(path string) MockIAMServerOption {
+	return func(s *MockIAMLookupServer) {
+		s.fixturePath = path
+	}
+}
+
+// Set how often the fixture file is checked for changes. Defaults to DefaultFixturePollInterval.
+func WithFixturePollInterval(interval time.Duration) MockIAMServerOption {
+	return func(s *MockIAMLookupServer) {
+		s.fixturePoll = interval
+	}
+}
+
+// Set the logger used to report fixture reloads. Defaults to a disabled logger.
+func WithMockLogger(logger zerolog.Logger) MockIAMServerOption {
+	return func(s *MockIAMLookupServer) {
+		s.logger = logger
+	}
+}
+
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFixture(path string) (fileVersion, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// loadFixtureFile loads the fixture file, and returns the version of the file that was loaded.
+func (s *MockIAMLookupServer) loadFixtureFile() (fileVersion, error) {
+	version, err := statFixture(s.fixturePath)
+	if err != nil {
+		return version, fmt.Errorf("unable to read fixture: %w", err)
+	}
+
+	fixture, err := ReadMockIAMFixture(s.fixturePath)
+	if err != nil {
+		return version, err
+	}
+
+	s.LoadFixture(fixture)
+	return version, nil
+}
+
+// watchFixture polls the fixture file, and reloads it on changes until done is closed.
+func (s *MockIAMLookupServer) watchFixture(loaded fileVersion, done <-chan struct{}) {
+	ticker := time.NewTicker(s.fixturePoll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		version, err := statFixture(s.fixturePath)
+		if err != nil || (version.modTime.Equal(loaded.modTime) && version.size == loaded.size) {
+			continue
+		}
+
+		// Remember the version even if it fails to load, so that a broken file is only reported once
+		loaded = version
+		_, err = s.loadFixtureFile()
+		if err != nil {
+			s.logger.Error().Err(err).Msgf("Unable to reload fixture '%s', keeping the previous data", s.fixturePath)
+			continue
+		}
+		s.logger.Info().Msgf("Reloaded fixture '%s'", s.fixturePath)
+	}
+}
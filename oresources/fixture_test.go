@@ -0,0 +1,95 @@
+package oresources
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMockIAMLookupServerFixture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iam.yaml")
+	write := func(data string, modTime time.Time) {
+		err := os.WriteFile(path, []byte(data), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Make sure the change is noticed, regardless of the file system's timestamp resolution
+		err = os.Chtimes(path, modTime, modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	write(`
+apps:
+  mockapp:
+    projects: [ent-mockapp-dev]
+    owners: [mockowner@entur.io]
+users:
+  mockuser@entur.io:
+    groups: [team-a]
+    roles:
+      ent-mockapp-dev: [mockrole]
+`, now)
+
+	// The fixture file is only watched while the server is running
+	server, err := NewMockIAMLookupServer(WithFixtureFile(path), WithFixturePollInterval(5*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = server.Stop()
+	})
+
+	ctx := context.Background()
+	client, err := NewIAMLookupClientWithAuth(ctx, server.URL(), AuthNone())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owners, err := client.AppOwners(ctx, "mockapp")
+	if err != nil || !slices.Equal(owners, []string{"mockowner@entur.io"}) {
+		t.Errorf("app owners do not match expected value\ngot: %v, %v\nwant: %v", owners, err, []string{"mockowner@entur.io"})
+	}
+	access, err := client.GCPUserHasRoleInProjects(ctx, "mockuser@entur.io", "mockrole", "ent-mockapp-dev")
+	if err != nil || !access {
+		t.Errorf("role from fixture was not granted\ngot: %t, %v\nwant: true, <nil>", access, err)
+	}
+
+	// Fixtures are reloaded on changes, and can be written in JSON
+	write(`{"users": {"mockuser@entur.io": {"groups": ["team-b"]}}}`, now.Add(time.Second))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		groups, err := client.EntraIDUserGroups(ctx, "mockuser@entur.io")
+		if err == nil && slices.Equal(groups, []string{"team-b"}) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("changed fixture was not reloaded\ngot: %v, %v\nwant: %v", groups, err, []string{"team-b"})
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// A broken fixture keeps the previous data
+	write(`users: [`, now.Add(2*time.Second))
+	time.Sleep(50 * time.Millisecond)
+	groups, err := client.EntraIDUserGroups(ctx, "mockuser@entur.io")
+	if err != nil || !slices.Equal(groups, []string{"team-b"}) {
+		t.Errorf("broken fixture did not keep the previous data\ngot: %v, %v\nwant: %v", groups, err, []string{"team-b"})
+	}
+}
+
+func TestMockIAMLookupServerFixtureMissing(t *testing.T) {
+	_, err := NewMockIAMLookupServer(WithFixtureFile(filepath.Join(t.TempDir(), "missing.yaml")))
+	if err == nil {
+		t.Errorf("missing fixture did not result in an error")
+	}
+}
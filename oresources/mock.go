@@ -1,13 +1,20 @@
 package oresources
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"mime"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"slices"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/rs/zerolog"
 )
 
 // -----------------------
@@ -34,19 +41,132 @@ func enforceJSON(next http.Handler) http.Handler {
 	})
 }
 
+// The handlerTransport type serves requests with a http.Handler in-memory, instead of sending them over the network.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, req)
+
+	// Like a real transport, fail if the request was canceled before the response was written
+	err := req.Context().Err()
+	if err != nil {
+		return nil, err
+	}
+
+	res := rec.Result()
+	res.Request = req
+	return res, nil
+}
+
+// HandlerTransport returns a http.RoundTripper serving all requests in-memory with the given handler, without opening any sockets.
+func HandlerTransport(handler http.Handler) http.RoundTripper {
+	return handlerTransport{handler: handler}
+}
+
 // -----------------------
 // Resource Servers
 // -----------------------
 
+const mockIAMLookupURL = "http://iam-lookup.mock" // Base URL of in-memory clients, never resolved
+
 const defaultReadHeaderTimeout = 10 * time.Second
 
 type MockIAMLookupServer struct {
-	server           *http.Server
-	port             int
-	url              string
+	server  *http.Server
+	handler http.Handler
+	port    int
+	url     string
+	logger  zerolog.Logger
+
+	fixturePath    string
+	fixturePoll    time.Duration
+	fixtureVersion fileVersion   // Version of the fixture file last loaded
+	watchDone      chan struct{} // Closed to stop watching the fixture file
+
+	mu               sync.RWMutex
 	appIDProjects    map[string][]string
 	userProjectRoles map[string]map[string][]string
 	userGroups       map[string][]string
+	appOwners        map[string][]string
+	requests         []MockIAMRequest
+	faults           map[string]*MockFault
+	authorize        func(authorization string) bool // Verifies the Authorization header, if set
+}
+
+// The MockIAMRequest type represents a request received by the mock server.
+type MockIAMRequest struct {
+	Endpoint      string // One of the IAMEndpoint constants
+	Authorization string // The Authorization header
+	Body          []byte
+	Time          time.Time
+}
+
+// Decode unmarshals the request body into v, e.g. a GCPUserAccessRequest for IAMEndpointGCPUserAccess.
+func (r MockIAMRequest) Decode(v any) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// The MockFault type describes a fault injected into the responses of an endpoint.
+type MockFault struct {
+	Latency       time.Duration // Delay before responding
+	Status        int           // Respond with this status instead, if set
+	MalformedJSON bool          // Respond with a malformed JSON body instead
+	Times         int           // Number of requests the fault applies to, or every request if zero
+}
+
+// instrument records every request to the endpoint, and applies any injected fault before responding.
+func (s *MockIAMLookupServer) instrument(endpoint string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		authorization := req.Header.Get("Authorization")
+
+		s.mu.Lock()
+		s.requests = append(s.requests, MockIAMRequest{Endpoint: endpoint, Authorization: authorization, Body: body, Time: time.Now()})
+		authorize := s.authorize
+		var fault MockFault
+		if f, ok := s.faults[endpoint]; ok {
+			fault = *f
+			f.Times--
+			if f.Times == 0 {
+				delete(s.faults, endpoint)
+			}
+		}
+		s.mu.Unlock()
+
+		if authorize != nil && !authorize(authorization) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		if fault.Latency > 0 {
+			select {
+			case <-req.Context().Done():
+				return
+			case <-time.After(fault.Latency):
+			}
+		}
+
+		switch {
+		case fault.Status != 0:
+			http.Error(w, "Injected fault", fault.Status)
+		case fault.MalformedJSON:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"malformed":`))
+		default:
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+			next.ServeHTTP(w, req)
+		}
+	})
 }
 
 func (s *MockIAMLookupServer) hGCPProjectIDs(w http.ResponseWriter, req *http.Request) {
@@ -116,6 +236,165 @@ func (s *MockIAMLookupServer) hEntraIDUserGroups(w http.ResponseWriter, req *htt
 	_ = json.NewEncoder(w).Encode(resBody)
 }
 
+func (s *MockIAMLookupServer) hEntraIDUserInGroup(w http.ResponseWriter, req *http.Request) {
+	var reqBody EntraIDUserInGroupRequest
+	err := json.NewDecoder(req.Body).Decode(&reqBody)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	var resBody EntraIDUserInGroupResponse
+
+	resBody.IsMember = slices.Contains(s.userGroups[reqBody.User], reqBody.Group)
+	_ = json.NewEncoder(w).Encode(resBody)
+}
+
+func (s *MockIAMLookupServer) hGCPUserRolesInProject(w http.ResponseWriter, req *http.Request) {
+	var reqBody GCPUserRolesRequest
+	err := json.NewDecoder(req.Body).Decode(&reqBody)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if !strings.HasPrefix(reqBody.Resource, "projects/") {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	reqBody.Resource = strings.TrimPrefix(reqBody.Resource, "projects/")
+
+	w.Header().Set("Content-Type", "application/json")
+	var resBody GCPUserRolesResponse
+
+	resBody.Roles = s.userProjectRoles[reqBody.Resource][reqBody.User]
+	_ = json.NewEncoder(w).Encode(resBody)
+}
+
+func (s *MockIAMLookupServer) hAppOwners(w http.ResponseWriter, req *http.Request) {
+	var reqBody AppOwnersRequest
+	err := json.NewDecoder(req.Body).Decode(&reqBody)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	owners, ok := s.appOwners[reqBody.AppID]
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(AppOwnersResponse{Owners: owners})
+}
+
+// SetAppIDProjects sets the GCP projects of an app.
+func (s *MockIAMLookupServer) SetAppIDProjects(appID string, projectIDs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.appIDProjects[appID] = projectIDs
+}
+
+// SetUserProjectRoles sets the roles of a user in a GCP project.
+func (s *MockIAMLookupServer) SetUserProjectRoles(email string, projectID string, roles []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project, ok := s.userProjectRoles[projectID]
+	if !ok {
+		project = map[string][]string{}
+		s.userProjectRoles[projectID] = project
+	}
+	project[email] = roles
+}
+
+// SetUserGroups sets the entra id groups of a user.
+func (s *MockIAMLookupServer) SetUserGroups(email string, groups []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.userGroups[email] = groups
+}
+
+// SetAppOwners sets the owners of an app.
+func (s *MockIAMLookupServer) SetAppOwners(appID string, owners []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.appOwners[appID] = owners
+}
+
+// RemoveUser removes the groups and project roles of a user.
+func (s *MockIAMLookupServer) RemoveUser(email string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.userGroups, email)
+	for _, project := range s.userProjectRoles {
+		delete(project, email)
+	}
+}
+
+// RemoveApp removes the projects and owners of an app, after which it is unknown.
+func (s *MockIAMLookupServer) RemoveApp(appID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.appIDProjects, appID)
+	delete(s.appOwners, appID)
+}
+
+// Requests returns all requests received by the server, in order. If endpoints are given, only requests to them are returned.
+func (s *MockIAMLookupServer) Requests(endpoints ...string) []MockIAMRequest {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var requests []MockIAMRequest
+	for _, r := range s.requests {
+		if len(endpoints) == 0 || slices.Contains(endpoints, r.Endpoint) {
+			requests = append(requests, r)
+		}
+	}
+	return requests
+}
+
+// ClearRequests forgets all received requests.
+func (s *MockIAMLookupServer) ClearRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = nil
+}
+
+// InjectFault makes the given endpoint respond with the fault, replacing any fault already injected into it.
+func (s *MockIAMLookupServer) InjectFault(endpoint string, fault MockFault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults[endpoint] = &fault
+}
+
+// ClearFaults removes all injected faults.
+func (s *MockIAMLookupServer) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = map[string]*MockFault{}
+}
+
+// Handler returns the routes of the server as a http.Handler, e.g. to serve them with a httptest.Server.
+func (s *MockIAMLookupServer) Handler() http.Handler {
+	return s.handler
+}
+
+// Client returns an IAM Lookup client which calls the server in-memory, without the server having to be started.
+func (s *MockIAMLookupServer) Client() *IAMLookupClient {
+	return NewIAMLookupClientWithHTTPClient(mockIAMLookupURL, &http.Client{Transport: HandlerTransport(s.handler)})
+}
+
 func (s *MockIAMLookupServer) URL() string {
 	return s.url
 }
@@ -143,12 +422,21 @@ func (s *MockIAMLookupServer) Start() error {
 		_ = s.server.Serve(l)
 	}()
 
+	if s.fixturePath != "" {
+		s.watchDone = make(chan struct{})
+		go s.watchFixture(s.fixtureVersion, s.watchDone)
+	}
+
 	return nil
 }
 
 func (s *MockIAMLookupServer) Stop() error {
 	err := s.server.Close()
 	s.url = ""
+	if s.watchDone != nil {
+		close(s.watchDone)
+		s.watchDone = nil
+	}
 	return err
 }
 
@@ -162,28 +450,42 @@ func WithPort(port int) MockIAMServerOption {
 
 func WithAppIDProjects(appID string, projectIDs []string) MockIAMServerOption {
 	return func(s *MockIAMLookupServer) {
-		s.appIDProjects[appID] = projectIDs
+		s.SetAppIDProjects(appID, projectIDs)
 	}
 }
 
 func WithUserProjectRoles(email string, projectID string, roles []string) MockIAMServerOption {
 	return func(s *MockIAMLookupServer) {
-		project, ok := s.userProjectRoles[projectID]
-		if !ok {
-			project = map[string][]string{}
-			s.userProjectRoles[projectID] = project
-		}
-
-		project[email] = roles
+		s.SetUserProjectRoles(email, projectID, roles)
 	}
 }
 
 func WithUserGroups(email string, groups []string) MockIAMServerOption {
 	return func(s *MockIAMLookupServer) {
-		s.userGroups[email] = groups
+		s.SetUserGroups(email, groups)
 	}
 }
 
+func WithAppOwners(appID string, owners []string) MockIAMServerOption {
+	return func(s *MockIAMLookupServer) {
+		s.SetAppOwners(appID, owners)
+	}
+}
+
+// Reject requests with 401 Unauthorized, unless check accepts their Authorization header.
+func WithAuthorizationCheck(check func(authorization string) bool) MockIAMServerOption {
+	return func(s *MockIAMLookupServer) {
+		s.authorize = check
+	}
+}
+
+// Reject requests with 401 Unauthorized, unless they are authenticated with the given bearer token, see AuthBearerToken.
+func WithRequiredBearerToken(token string) MockIAMServerOption {
+	return WithAuthorizationCheck(func(authorization string) bool {
+		return authorization == fmt.Sprintf("Bearer %s", token)
+	})
+}
+
 // NewMockIAMLookupServer returns a new mock server which mimics the functionality of the IAM Lookup resource.
 // It can be used along with NewIAMClient for local client -> server testing.
 func NewMockIAMLookupServer(opts ...MockIAMServerOption) (*MockIAMLookupServer, error) {
@@ -191,21 +493,42 @@ func NewMockIAMLookupServer(opts ...MockIAMServerOption) (*MockIAMLookupServer,
 		appIDProjects:    map[string][]string{},
 		userProjectRoles: map[string]map[string][]string{},
 		userGroups:       map[string][]string{},
+		appOwners:        map[string][]string{},
+		faults:           map[string]*MockFault{},
+		fixturePoll:      DefaultFixturePollInterval,
+		logger:           zerolog.Nop(),
 	}
 
 	for _, opt := range opts {
 		opt(s)
 	}
 
+	if s.fixturePath != "" {
+		version, err := s.loadFixtureFile()
+		if err != nil {
+			return nil, err
+		}
+		s.fixtureVersion = version
+	}
+
 	if s.port < 0 {
 		return nil, fmt.Errorf("the assigned port must be a positive integer")
 	}
 
 	mux := http.NewServeMux()
-	mux.Handle("POST /app/projects/gcp", enforceJSON(http.HandlerFunc(s.hGCPProjectIDs)))
-	mux.Handle("POST /access/gcp", enforceJSON(http.HandlerFunc(s.hGCPUserHasRoleInProjects)))
-	mux.Handle("POST /groups/entraid", enforceJSON(http.HandlerFunc(s.hEntraIDUserGroups)))
+	routes := map[string]http.HandlerFunc{
+		IAMEndpointGCPAppProjects:     s.hGCPProjectIDs,
+		IAMEndpointGCPUserAccess:      s.hGCPUserHasRoleInProjects,
+		IAMEndpointGCPUserRoles:       s.hGCPUserRolesInProject,
+		IAMEndpointEntraIDUserGroups:  s.hEntraIDUserGroups,
+		IAMEndpointEntraIDUserInGroup: s.hEntraIDUserInGroup,
+		IAMEndpointAppOwners:          s.hAppOwners,
+	}
+	for endpoint, handler := range routes {
+		mux.Handle(fmt.Sprintf("POST %s", endpoint), s.instrument(endpoint, enforceJSON(handler)))
+	}
 
+	s.handler = mux
 	s.server = &http.Server{
 		ReadHeaderTimeout: defaultReadHeaderTimeout,
 		Handler:           mux,
@@ -0,0 +1,164 @@
+package oresources
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newTestMockServer(t *testing.T, opts ...MockIAMServerOption) (*MockIAMLookupServer, *IAMLookupClient) {
+	t.Helper()
+
+	server, err := NewMockIAMLookupServer(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, server.Client().WithRetryPolicy(testRetryPolicy)
+}
+
+func TestMockIAMLookupServerMutation(t *testing.T) {
+	server, client := newTestMockServer(t, WithAppIDProjects("mockapp", []string{"ent-mockapp-dev"}))
+	ctx := context.Background()
+
+	server.SetUserProjectRoles("mockuser@entur.io", "ent-mockapp-dev", []string{"mockrole"})
+	access, err := client.GCPUserHasRoleInProjects(ctx, "mockuser@entur.io", "mockrole", "ent-mockapp-dev")
+	if err != nil || !access {
+		t.Errorf("role set while running was not granted\ngot: %t, %v\nwant: true, <nil>", access, err)
+	}
+
+	server.RemoveUser("mockuser@entur.io")
+	access, err = client.GCPUserHasRoleInProjects(ctx, "mockuser@entur.io", "mockrole", "ent-mockapp-dev")
+	if err != nil || access {
+		t.Errorf("role of removed user was granted\ngot: %t, %v\nwant: false, <nil>", access, err)
+	}
+
+	server.RemoveApp("mockapp")
+	_, err = client.AppOwners(ctx, "mockapp")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("removed app was found\ngot: %v\nwant: %v", err, ErrNotFound)
+	}
+
+	requests := server.Requests(IAMEndpointGCPUserAccess)
+	if len(requests) != 2 {
+		t.Fatalf("number of recorded requests does not match expected value\ngot: %d\nwant: %d", len(requests), 2)
+	}
+	var body GCPUserAccessRequest
+	err = requests[0].Decode(&body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := GCPUserAccessRequest{User: "mockuser@entur.io", Role: "mockrole", Resource: "projects/ent-mockapp-dev"}
+	if body != want {
+		t.Errorf("recorded request does not match expected value\ngot: %v\nwant: %v", body, want)
+	}
+	if len(server.Requests()) != 3 {
+		t.Errorf("number of recorded requests does not match expected value\ngot: %d\nwant: %d", len(server.Requests()), 3)
+	}
+
+	server.ClearRequests()
+	if len(server.Requests()) != 0 {
+		t.Errorf("recorded requests were not cleared")
+	}
+}
+
+func TestMockIAMLookupServerFaults(t *testing.T) {
+	tests := []struct {
+		title    string
+		fault    MockFault
+		timeout  time.Duration
+		want     error
+		requests int
+	}{
+		{
+			title:    "Transient server error is retried",
+			fault:    MockFault{Status: http.StatusInternalServerError, Times: 1},
+			requests: 2,
+		},
+		{
+			title:    "Persistent server error",
+			fault:    MockFault{Status: http.StatusServiceUnavailable},
+			want:     ErrUnavailable,
+			requests: 3,
+		},
+		{
+			title:    "Unauthorized",
+			fault:    MockFault{Status: http.StatusUnauthorized},
+			want:     ErrUnauthorized,
+			requests: 1,
+		},
+		{
+			title:    "Latency",
+			fault:    MockFault{Latency: time.Second},
+			timeout:  50 * time.Millisecond,
+			want:     context.DeadlineExceeded,
+			requests: 1,
+		},
+	}
+
+	for _, test := range tests {
+		tmp := test
+		t.Run(tmp.title, func(t *testing.T) {
+			t.Parallel()
+
+			server, client := newTestMockServer(t, WithUserGroups("mockuser@entur.io", []string{"team-a"}))
+			server.InjectFault(IAMEndpointEntraIDUserGroups, tmp.fault)
+
+			ctx := context.Background()
+			if tmp.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tmp.timeout)
+				defer cancel()
+			}
+
+			groups, err := client.EntraIDUserGroups(ctx, "mockuser@entur.io")
+			if !errors.Is(err, tmp.want) {
+				t.Errorf("lookup error does not match expected value\ngot: %v\nwant: %v", err, tmp.want)
+			}
+			if tmp.want == nil && !slices.Equal(groups, []string{"team-a"}) {
+				t.Errorf("groups do not match expected value\ngot: %v\nwant: %v", groups, []string{"team-a"})
+			}
+			if n := len(server.Requests()); n != tmp.requests {
+				t.Errorf("number of recorded requests does not match expected value\ngot: %d\nwant: %d", n, tmp.requests)
+			}
+		})
+	}
+
+	t.Run("Malformed JSON", func(t *testing.T) {
+		t.Parallel()
+
+		server, client := newTestMockServer(t)
+		server.InjectFault(IAMEndpointAppOwners, MockFault{MalformedJSON: true})
+
+		_, err := client.AppOwners(context.Background(), "mockapp")
+		if err == nil {
+			t.Errorf("malformed response did not result in an error")
+		}
+
+		// Faults can be cleared
+		server.ClearFaults()
+		_, err = client.EntraIDUserGroups(context.Background(), "mockuser@entur.io")
+		if err != nil {
+			t.Errorf("lookup error does not match expected value\ngot: %v\nwant: <nil>", err)
+		}
+	})
+}
+
+func TestMockIAMLookupServerHandler(t *testing.T) {
+	server, err := NewMockIAMLookupServer(WithUserGroups("mockuser@entur.io", []string{"team-a"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(server.Handler())
+	defer srv.Close()
+
+	client := NewIAMLookupClientWithHTTPClient(srv.URL, srv.Client())
+	groups, err := client.EntraIDUserGroups(context.Background(), "mockuser@entur.io")
+	if err != nil || !slices.Equal(groups, []string{"team-a"}) {
+		t.Errorf("groups do not match expected value\ngot: %v, %v\nwant: %v", groups, err, []string{"team-a"})
+	}
+}
@@ -4,15 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
+	"slices"
 	"strings"
-	"time"
-
-	"github.com/entur/go-logging"
-	"google.golang.org/api/idtoken"
 )
 
 // -----------------------
@@ -41,12 +38,25 @@ func request(ctx context.Context, client *http.Client, method string, url string
 
 	res, err := client.Do(req)
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("http '%s' request failed: %w", method, err)
+		if ctx.Err() != nil {
+			return http.StatusInternalServerError, fmt.Errorf("http '%s' request failed: %w", method, err)
+		}
+		return http.StatusServiceUnavailable, fmt.Errorf("http '%s' request failed: %w: %w", method, ErrUnavailable, err)
 	}
 	defer func() {
 		_ = res.Body.Close()
 	}()
 
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+		return res.StatusCode, &StatusError{
+			Method:     method,
+			URL:        url,
+			StatusCode: res.StatusCode,
+			Body:       strings.TrimSpace(string(body)),
+		}
+	}
+
 	if res.Header.Get("Content-Type") == "application/json" {
 		dec := json.NewDecoder(res.Body)
 		err = dec.Decode(resBody)
@@ -62,12 +72,60 @@ func request(ctx context.Context, client *http.Client, method string, url string
 // Resource Clients
 // -----------------------
 
-const defaultTimeout = 10 * time.Second
-const defaultDialerTimeout = 5 * time.Second
+// Endpoints of the IAM Lookup resource, all of which accept POST requests. The request and response payloads of every
+// endpoint are written out in iamContract (contract_test.go), which both IAMLookupClient and MockIAMLookupServer are
+// tested against.
+const (
+	IAMEndpointGCPAppProjects     = "/app/projects/gcp"
+	IAMEndpointGCPUserAccess      = "/access/gcp"
+	IAMEndpointGCPUserRoles       = "/roles/gcp"
+	IAMEndpointEntraIDUserGroups  = "/groups/entraid"
+	IAMEndpointEntraIDUserInGroup = "/groups/entraid/member"
+	IAMEndpointAppOwners          = "/app/owners"
+)
 
 type IAMLookupClient struct {
-	client *http.Client
-	url    string
+	client      *JSONClient
+	cache       *Cache
+	concurrency int
+}
+
+func (iam *IAMLookupClient) post(ctx context.Context, path string, reqBody any, resBody any) error {
+	return iam.client.Post(ctx, path, reqBody, resBody)
+}
+
+// WithCache returns a copy of the client which caches its lookups in the given cache, see Cache.
+// The copy shares the underlying http client with the original client, which stays uncached.
+func (iam *IAMLookupClient) WithCache(cache *Cache) *IAMLookupClient {
+	cp := *iam
+	cp.cache = cache
+	return &cp
+}
+
+// cacheKey returns the cache key of a lookup, including the resource URL, so that a cache shared between clients for
+// different IAM Lookup resources never returns the result of one for another.
+func (iam *IAMLookupClient) cacheKey(parts ...string) []string {
+	return append([]string{iam.client.URL()}, parts...)
+}
+
+// Cache returns the cache used by the client, or nil if lookups are not cached.
+func (iam *IAMLookupClient) Cache() *Cache {
+	return iam.cache
+}
+
+// WithRetryPolicy returns a copy of the client which retries lookups failing with ErrUnavailable according to the policy.
+// By default, clients use DefaultRetryPolicy.
+func (iam *IAMLookupClient) WithRetryPolicy(policy RetryPolicy) *IAMLookupClient {
+	cp := *iam
+	cp.client = iam.client.WithRetryPolicy(policy)
+	return &cp
+}
+
+// WithCircuitBreaker returns a copy of the client which fails fast with ErrCircuitOpen while the breaker is open, see CircuitBreaker.
+func (iam *IAMLookupClient) WithCircuitBreaker(breaker *CircuitBreaker) *IAMLookupClient {
+	cp := *iam
+	cp.client = iam.client.WithCircuitBreaker(breaker)
+	return &cp
 }
 
 type GCPAppProjectsRequest struct {
@@ -78,19 +136,25 @@ type GCPAppProjectsResponse struct {
 	ProjectIDs []string `json:"projects"`
 }
 
-// List all of the GCP project ids associated with an app-factory id.
+// List all of the GCP project ids associated with an app-factory id. An unknown app has no projects.
 func (iam *IAMLookupClient) GCPAppProjectIDs(ctx context.Context, appID string) ([]string, error) {
-	url := fmt.Sprintf("%s/app/projects/gcp", iam.url)
+	projectIDs, err := cached(ctx, iam.cache, iam.cacheKey("GCPAppProjectIDs", appID), func(ctx context.Context) ([]string, error) {
+		return iam.gcpAppProjectIDs(ctx, appID)
+	})
+	return slices.Clone(projectIDs), err
+}
+
+func (iam *IAMLookupClient) gcpAppProjectIDs(ctx context.Context, appID string) ([]string, error) {
 	reqBody := GCPAppProjectsRequest{
 		AppID: appID,
 	}
 	resBody := GCPAppProjectsResponse{}
 
-	status, err := request(ctx, iam.client, http.MethodPost, url, nil, reqBody, &resBody)
-	if err != nil {
-		return nil, err
+	err := iam.post(ctx, IAMEndpointGCPAppProjects, reqBody, &resBody)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
 	}
-	if status != http.StatusOK && status != http.StatusNotFound {
+	if err != nil {
 		return nil, err
 	}
 
@@ -109,24 +173,19 @@ type GCPUserAccessResponse struct {
 
 // Check if the user (email) has the specified Sub-Orchestrator role in *all* of the given GCP projects.
 func (iam *IAMLookupClient) GCPUserHasRoleInProjects(ctx context.Context, email string, role string, projectIDs ...string) (bool, error) {
-	url := fmt.Sprintf("%s/access/gcp", iam.url)
-	reqBody := GCPUserAccessRequest{
-		User: email,
-		Role: role,
-	}
-	resBody := GCPUserAccessResponse{}
+	key := iam.cacheKey(append([]string{"GCPUserHasRoleInProjects", email, role}, projectIDs...)...)
+	return cached(ctx, iam.cache, key, func(ctx context.Context) (bool, error) {
+		return iam.gcpUserHasRoleInProjects(ctx, email, role, projectIDs...)
+	})
+}
 
+func (iam *IAMLookupClient) gcpUserHasRoleInProjects(ctx context.Context, email string, role string, projectIDs ...string) (bool, error) {
 	for i := range projectIDs {
-		reqBody.Resource = fmt.Sprintf("projects/%s", projectIDs[i])
-
-		status, err := request(ctx, iam.client, http.MethodPost, url, nil, reqBody, &resBody)
+		hasAccess, err := iam.gcpUserHasRoleInProject(ctx, email, role, projectIDs[i])
 		if err != nil {
 			return false, err
 		}
-		if status != http.StatusOK {
-			return false, nil
-		}
-		if !resBody.HasAccess {
+		if !hasAccess {
 			return false, nil
 		}
 	}
@@ -134,6 +193,22 @@ func (iam *IAMLookupClient) GCPUserHasRoleInProjects(ctx context.Context, email
 	return true, nil
 }
 
+func (iam *IAMLookupClient) gcpUserHasRoleInProject(ctx context.Context, email string, role string, projectID string) (bool, error) {
+	reqBody := GCPUserAccessRequest{
+		User:     email,
+		Role:     role,
+		Resource: fmt.Sprintf("projects/%s", projectID),
+	}
+	resBody := GCPUserAccessResponse{}
+
+	err := iam.post(ctx, IAMEndpointGCPUserAccess, reqBody, &resBody)
+	if err != nil {
+		return false, err
+	}
+
+	return resBody.HasAccess, nil
+}
+
 type EntraIDUserGroupsRequest struct {
 	User string `json:"user"`
 }
@@ -144,50 +219,143 @@ type EntraIDUserGroupsResponse struct {
 
 // List all of the entra id groups (without the @ suffix) that a user (email) belongs to.
 func (iam *IAMLookupClient) EntraIDUserGroups(ctx context.Context, email string) ([]string, error) {
-	url := fmt.Sprintf("%s/groups/entraid", iam.url)
+	groups, err := cached(ctx, iam.cache, iam.cacheKey("EntraIDUserGroups", email), func(ctx context.Context) ([]string, error) {
+		return iam.entraIDUserGroups(ctx, email)
+	})
+	return slices.Clone(groups), err
+}
+
+func (iam *IAMLookupClient) entraIDUserGroups(ctx context.Context, email string) ([]string, error) {
 	reqBody := EntraIDUserGroupsRequest{
 		User: email,
 	}
 	resBody := EntraIDUserGroupsResponse{}
 
-	status, err := request(ctx, iam.client, http.MethodPost, url, nil, reqBody, &resBody)
+	err := iam.post(ctx, IAMEndpointEntraIDUserGroups, reqBody, &resBody)
 	if err != nil {
 		return nil, err
 	}
-	if status != http.StatusOK {
+
+	return resBody.Groups, nil
+}
+
+type EntraIDUserInGroupRequest struct {
+	User  string `json:"user"`
+	Group string `json:"group"`
+}
+
+type EntraIDUserInGroupResponse struct {
+	IsMember bool `json:"member"`
+}
+
+// Check if the user (email) is a member of the entra id group (without the @ suffix).
+func (iam *IAMLookupClient) EntraIDUserInGroup(ctx context.Context, email string, group string) (bool, error) {
+	return cached(ctx, iam.cache, iam.cacheKey("EntraIDUserInGroup", email, group), func(ctx context.Context) (bool, error) {
+		return iam.entraIDUserInGroup(ctx, email, group)
+	})
+}
+
+func (iam *IAMLookupClient) entraIDUserInGroup(ctx context.Context, email string, group string) (bool, error) {
+	reqBody := EntraIDUserInGroupRequest{
+		User:  email,
+		Group: group,
+	}
+	resBody := EntraIDUserInGroupResponse{}
+
+	err := iam.post(ctx, IAMEndpointEntraIDUserInGroup, reqBody, &resBody)
+	if err != nil {
+		return false, err
+	}
+
+	return resBody.IsMember, nil
+}
+
+type GCPUserRolesRequest struct {
+	User     string `json:"user"`
+	Resource string `json:"resource"`
+}
+
+type GCPUserRolesResponse struct {
+	Roles []string `json:"roles"`
+}
+
+// List all of the Sub-Orchestrator roles that the user (email) holds in the given GCP project.
+func (iam *IAMLookupClient) GCPUserRolesInProject(ctx context.Context, email string, projectID string) ([]string, error) {
+	roles, err := cached(ctx, iam.cache, iam.cacheKey("GCPUserRolesInProject", email, projectID), func(ctx context.Context) ([]string, error) {
+		return iam.gcpUserRolesInProject(ctx, email, projectID)
+	})
+	return slices.Clone(roles), err
+}
+
+func (iam *IAMLookupClient) gcpUserRolesInProject(ctx context.Context, email string, projectID string) ([]string, error) {
+	reqBody := GCPUserRolesRequest{
+		User:     email,
+		Resource: fmt.Sprintf("projects/%s", projectID),
+	}
+	resBody := GCPUserRolesResponse{}
+
+	err := iam.post(ctx, IAMEndpointGCPUserRoles, reqBody, &resBody)
+	if err != nil {
 		return nil, err
 	}
 
-	return resBody.Groups, nil
+	return resBody.Roles, nil
 }
 
-type IAMClientOption = idtoken.ClientOption
+type AppOwnersRequest struct {
+	AppID string `json:"appId"`
+}
+
+type AppOwnersResponse struct {
+	Owners []string `json:"owners"`
+}
+
+// List all of the owners (emails) of an app-factory id. An unknown app results in ErrNotFound.
+func (iam *IAMLookupClient) AppOwners(ctx context.Context, appID string) ([]string, error) {
+	owners, err := cached(ctx, iam.cache, iam.cacheKey("AppOwners", appID), func(ctx context.Context) ([]string, error) {
+		return iam.appOwners(ctx, appID)
+	})
+	return slices.Clone(owners), err
+}
+
+func (iam *IAMLookupClient) appOwners(ctx context.Context, appID string) ([]string, error) {
+	reqBody := AppOwnersRequest{
+		AppID: appID,
+	}
+	resBody := AppOwnersResponse{}
+
+	err := iam.post(ctx, IAMEndpointAppOwners, reqBody, &resBody)
+	if err != nil {
+		return nil, err
+	}
+
+	return resBody.Owners, nil
+}
+
+type IAMClientOption = ClientOption
 
 // NewIAMLookupClient returns a http client which can be used against the IAM Lookup Resource.
-// It can also be used along with NewMockIAMServer for local client -> server testing.
+// Requests are authenticated according to AuthDiscover, see NewIAMLookupClientWithAuth to require id tokens.
 func NewIAMLookupClient(ctx context.Context, url string, opts ...IAMClientOption) (*IAMLookupClient, error) {
-	logger := logging.Ctx(ctx)
+	return NewIAMLookupClientWithAuth(ctx, url, AuthDiscover(opts...))
+}
 
-	client, err := idtoken.NewClient(ctx, url, opts...)
+// NewIAMLookupClientWithAuth returns a client for the IAM Lookup Resource, which authenticates its requests with auth.
+func NewIAMLookupClientWithAuth(ctx context.Context, url string, auth ClientAuth) (*IAMLookupClient, error) {
+	client, err := newJSONClient(ctx, "IAMLookup", url, auth)
 	if err != nil {
-		errStr := err.Error()
-		if !strings.HasPrefix(errStr, "idtoken: unsupported credentials type") && !strings.HasPrefix(errStr, "google: could not find default credentials") {
-			return nil, fmt.Errorf("unable to create iam client: %w", err)
-		}
-
-		logger.Debug().Msg("Unable to discover idtoken credentials, defaulting to http.Client for IAMLookup")
-		client = &http.Client{
-			Timeout: defaultTimeout,
-			Transport: &http.Transport{
-				Dial: (&net.Dialer{
-					Timeout: defaultDialerTimeout,
-				}).Dial,
-			},
-		}
+		return nil, fmt.Errorf("unable to create iam client: %w", err)
 	}
 
 	return &IAMLookupClient{
 		client: client,
-		url:    url,
 	}, nil
 }
+
+// NewIAMLookupClientWithHTTPClient returns a client for the IAM Lookup Resource, which sends all requests through the given
+// http client instead of authenticating with an id token. See MockIAMLookupServer.Client for an in-memory client.
+func NewIAMLookupClientWithHTTPClient(url string, client *http.Client) *IAMLookupClient {
+	return &IAMLookupClient{
+		client: NewJSONClientWithHTTPClient(url, client),
+	}
+}
@@ -0,0 +1,101 @@
+package oresources
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestIAMLookupClient(t *testing.T) {
+	server, err := NewMockIAMLookupServer(
+		WithAppIDProjects("mockapp", []string{"ent-mockapp-dev", "ent-mockapp-prd"}),
+		WithAppOwners("mockapp", []string{"mockowner@entur.io"}),
+		WithUserProjectRoles("mockuser@entur.io", "ent-mockapp-dev", []string{"mockrole", "otherrole"}),
+		WithUserGroups("mockuser@entur.io", []string{"team-a", "team-b"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := server.Client()
+
+	tests := []struct {
+		title  string
+		lookup func(ctx context.Context) (any, error)
+		want   any
+		err    error
+	}{
+		{
+			title: "GCPAppProjectIDs",
+			lookup: func(ctx context.Context) (any, error) {
+				return client.GCPAppProjectIDs(ctx, "mockapp")
+			},
+			want: []string{"ent-mockapp-dev", "ent-mockapp-prd"},
+		},
+		{
+			title: "GCPUserHasRoleInProjects",
+			lookup: func(ctx context.Context) (any, error) {
+				return client.GCPUserHasRoleInProjects(ctx, "mockuser@entur.io", "mockrole", "ent-mockapp-dev", "ent-mockapp-prd")
+			},
+			want: false,
+		},
+		{
+			title: "GCPUserRolesInProject",
+			lookup: func(ctx context.Context) (any, error) {
+				return client.GCPUserRolesInProject(ctx, "mockuser@entur.io", "ent-mockapp-dev")
+			},
+			want: []string{"mockrole", "otherrole"},
+		},
+		{
+			title: "EntraIDUserGroups",
+			lookup: func(ctx context.Context) (any, error) {
+				return client.EntraIDUserGroups(ctx, "mockuser@entur.io")
+			},
+			want: []string{"team-a", "team-b"},
+		},
+		{
+			title: "EntraIDUserInGroup",
+			lookup: func(ctx context.Context) (any, error) {
+				return client.EntraIDUserInGroup(ctx, "mockuser@entur.io", "team-b")
+			},
+			want: true,
+		},
+		{
+			title: "EntraIDUserInGroup not a member",
+			lookup: func(ctx context.Context) (any, error) {
+				return client.EntraIDUserInGroup(ctx, "mockuser@entur.io", "team-c")
+			},
+			want: false,
+		},
+		{
+			title: "AppOwners",
+			lookup: func(ctx context.Context) (any, error) {
+				return client.AppOwners(ctx, "mockapp")
+			},
+			want: []string{"mockowner@entur.io"},
+		},
+		{
+			title: "AppOwners unknown app",
+			lookup: func(ctx context.Context) (any, error) {
+				return client.AppOwners(ctx, "unknown")
+			},
+			want: []string(nil),
+			err:  ErrNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		tmp := test
+		t.Run(tmp.title, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tmp.lookup(context.Background())
+			if !errors.Is(err, tmp.err) {
+				t.Errorf("lookup error does not match expected value\ngot: %v\nwant: %v", err, tmp.err)
+			}
+			if !reflect.DeepEqual(got, tmp.want) {
+				t.Errorf("lookup result does not match expected value\ngot: %v\nwant: %v", got, tmp.want)
+			}
+		})
+	}
+}
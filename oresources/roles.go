@@ -0,0 +1,138 @@
+package oresources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// -----------------------
+// Role Checks
+// -----------------------
+
+const DefaultRoleCheckConcurrency = 8 // Default maximum number of projects checked at the same time.
+
+type RoleCheckStatus string
+
+const (
+	RoleGranted    RoleCheckStatus = "granted" // The user has the role in the project
+	RoleDenied     RoleCheckStatus = "denied"  // The user does not have the role in the project
+	RoleCheckError RoleCheckStatus = "error"   // The role could not be checked, see ProjectRoleCheck.Err
+)
+
+// The ProjectRoleCheck type represents the result of checking a role in a single GCP project.
+type ProjectRoleCheck struct {
+	ProjectID string
+	Status    RoleCheckStatus
+	Err       error
+}
+
+// The RoleCheck type represents the result of checking a role in several GCP projects, with a breakdown per project.
+type RoleCheck struct {
+	Email    string
+	Role     string
+	Projects []ProjectRoleCheck // In the same order as the checked projects
+}
+
+// Granted reports whether the role was granted in all of the checked projects.
+func (rc RoleCheck) Granted() bool {
+	for _, project := range rc.Projects {
+		if project.Status != RoleGranted {
+			return false
+		}
+	}
+	return true
+}
+
+// Denied returns the ids of the projects in which the role was denied.
+func (rc RoleCheck) Denied() []string {
+	return rc.projectIDs(RoleDenied)
+}
+
+// Failed returns the ids of the projects in which the role could not be checked.
+func (rc RoleCheck) Failed() []string {
+	return rc.projectIDs(RoleCheckError)
+}
+
+// Err returns the errors of all projects in which the role could not be checked, or nil.
+func (rc RoleCheck) Err() error {
+	var errs []error
+	for _, project := range rc.Projects {
+		if project.Err != nil {
+			errs = append(errs, fmt.Errorf("project '%s': %w", project.ProjectID, project.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Explain returns a message, meant for the user, describing in which projects the role is missing or could not be checked.
+// It is empty if the role was granted in all projects.
+func (rc RoleCheck) Explain() string {
+	var lines []string
+	if denied := rc.Denied(); len(denied) > 0 {
+		lines = append(lines, fmt.Sprintf("User '%s' is missing the role '%s' in the GCP projects: %s", rc.Email, rc.Role, strings.Join(denied, ", ")))
+	}
+	if failed := rc.Failed(); len(failed) > 0 {
+		lines = append(lines, fmt.Sprintf("Unable to check the role '%s' of user '%s' in the GCP projects: %s", rc.Role, rc.Email, strings.Join(failed, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (rc RoleCheck) projectIDs(status RoleCheckStatus) []string {
+	var projectIDs []string
+	for _, project := range rc.Projects {
+		if project.Status == status {
+			projectIDs = append(projectIDs, project.ProjectID)
+		}
+	}
+	return projectIDs
+}
+
+// WithConcurrency returns a copy of the client which checks at most n projects at the same time in CheckGCPUserRoleInProjects.
+// By default, clients use DefaultRoleCheckConcurrency.
+func (iam *IAMLookupClient) WithConcurrency(n int) *IAMLookupClient {
+	cp := *iam
+	cp.concurrency = n
+	return &cp
+}
+
+// Check if the user (email) has the specified Sub-Orchestrator role in each of the given GCP projects, concurrently.
+// Unlike GCPUserHasRoleInProjects, all projects are checked, and the result tells in which projects the role is missing.
+func (iam *IAMLookupClient) CheckGCPUserRoleInProjects(ctx context.Context, email string, role string, projectIDs ...string) RoleCheck {
+	rc := RoleCheck{
+		Email:    email,
+		Role:     role,
+		Projects: make([]ProjectRoleCheck, len(projectIDs)),
+	}
+
+	concurrency := iam.concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultRoleCheckConcurrency
+	}
+
+	var group errgroup.Group
+	group.SetLimit(concurrency)
+	for i, projectID := range projectIDs {
+		group.Go(func() error {
+			key := iam.cacheKey("GCPUserHasRoleInProjects", email, role, projectID)
+			hasAccess, err := cached(ctx, iam.cache, key, func(ctx context.Context) (bool, error) {
+				return iam.gcpUserHasRoleInProject(ctx, email, role, projectID)
+			})
+
+			check := ProjectRoleCheck{ProjectID: projectID, Status: RoleDenied, Err: err}
+			if err != nil {
+				check.Status = RoleCheckError
+			} else if hasAccess {
+				check.Status = RoleGranted
+			}
+			rc.Projects[i] = check
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	return rc
+}
@@ -0,0 +1,68 @@
+package oresources
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckGCPUserRoleInProjects(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	client := newHandlerClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		var reqBody GCPUserAccessRequest
+		_ = json.NewDecoder(r.Body).Decode(&reqBody)
+		switch reqBody.Resource {
+		case "projects/ent-broken-dev":
+			http.Error(w, "mock error", http.StatusForbidden)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(GCPUserAccessResponse{HasAccess: reqBody.Resource != "projects/ent-denied-dev"})
+		}
+	}))
+	client = client.WithConcurrency(2)
+
+	projectIDs := []string{"ent-a-dev", "ent-denied-dev", "ent-b-dev", "ent-broken-dev", "ent-c-dev"}
+	rc := client.CheckGCPUserRoleInProjects(context.Background(), "mockuser@entur.io", "mockrole", projectIDs...)
+
+	want := []RoleCheckStatus{RoleGranted, RoleDenied, RoleGranted, RoleCheckError, RoleGranted}
+	for i, project := range rc.Projects {
+		if project.ProjectID != projectIDs[i] || project.Status != want[i] {
+			t.Errorf("role check of project %d does not match expected value\ngot: %s %s\nwant: %s %s", i, project.ProjectID, project.Status, projectIDs[i], want[i])
+		}
+	}
+
+	if rc.Granted() {
+		t.Errorf("role check was granted, even though it was denied in some projects")
+	}
+	if !slices.Equal(rc.Denied(), []string{"ent-denied-dev"}) || !slices.Equal(rc.Failed(), []string{"ent-broken-dev"}) {
+		t.Errorf("denied and failed projects do not match expected value\ngot: %v, %v\nwant: %v, %v", rc.Denied(), rc.Failed(), []string{"ent-denied-dev"}, []string{"ent-broken-dev"})
+	}
+	if !errors.Is(rc.Err(), ErrUnauthorized) {
+		t.Errorf("role check error does not match expected value\ngot: %v\nwant: %v", rc.Err(), ErrUnauthorized)
+	}
+
+	explanation := "User 'mockuser@entur.io' is missing the role 'mockrole' in the GCP projects: ent-denied-dev\n" +
+		"Unable to check the role 'mockrole' of user 'mockuser@entur.io' in the GCP projects: ent-broken-dev"
+	if rc.Explain() != explanation {
+		t.Errorf("role check explanation does not match expected value\ngot: %s\nwant: %s", rc.Explain(), explanation)
+	}
+
+	if maxInFlight.Load() > 2 {
+		t.Errorf("role checks exceeded the concurrency limit\ngot: %d\nwant: <= %d", maxInFlight.Load(), 2)
+	}
+}
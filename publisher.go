@@ -0,0 +1,183 @@
+package orchestrator
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"github.com/entur/go-logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// -----------------------
+// Publishing
+// -----------------------
+
+const DefaultMaxPublishers = 16 // Default maximum number of idle response topic publishers kept open.
+
+// The PublishRetryPolicy type describes how failed response publishes are retried, using exponential backoff.
+type PublishRetryPolicy struct {
+	MaxAttempts    int           // Total number of publish attempts, including the first one
+	InitialBackoff time.Duration // Backoff before the first retry, doubled for every later retry
+	MaxBackoff     time.Duration // Upper bound of the backoff between retries
+}
+
+// Default retry policy used when publishing responses.
+var DefaultPublishRetryPolicy = PublishRetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 250 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+type cachedPublisher struct {
+	topic     string
+	publisher *pubsub.Publisher
+	users     int           // Number of events currently publishing through this publisher
+	element   *list.Element // Position in the least recently used list
+}
+
+// publisherCache keeps a bounded number of idle publishers open, evicting and stopping the least recently used ones.
+// Publishers that are in use are never evicted, so the cache may temporarily exceed its bound.
+type publisherCache struct {
+	mu         sync.Mutex
+	client     *pubsub.Client
+	max        int
+	ordering   bool
+	publishers map[string]*cachedPublisher
+	lru        *list.List // Most recently used first
+}
+
+func newPublisherCache(client *pubsub.Client, maxPublishers int, ordering bool) *publisherCache {
+	return &publisherCache{
+		client:     client,
+		max:        maxPublishers,
+		ordering:   ordering,
+		publishers: map[string]*cachedPublisher{},
+		lru:        list.New(),
+	}
+}
+
+// acquire returns the publisher for the given topic, creating it if necessary.
+// The publisher must be released when the caller is done publishing.
+func (c *publisherCache) acquire(topic string) *cachedPublisher {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cp, ok := c.publishers[topic]
+	if !ok {
+		publisher := c.client.Publisher(topic)
+		publisher.EnableMessageOrdering = c.ordering
+
+		cp = &cachedPublisher{
+			topic:     topic,
+			publisher: publisher,
+		}
+		cp.element = c.lru.PushFront(cp)
+		c.publishers[topic] = cp
+	} else {
+		c.lru.MoveToFront(cp.element)
+	}
+
+	cp.users++
+	return cp
+}
+
+// release marks the publisher as no longer in use, and stops any idle publishers exceeding the bound.
+func (c *publisherCache) release(cp *cachedPublisher) {
+	c.mu.Lock()
+	cp.users--
+
+	var evicted []*cachedPublisher
+	for e := c.lru.Back(); e != nil && len(c.publishers) > c.max; {
+		prev := e.Prev()
+		candidate, _ := e.Value.(*cachedPublisher)
+		if candidate.users == 0 {
+			c.lru.Remove(e)
+			delete(c.publishers, candidate.topic)
+			evicted = append(evicted, candidate)
+		}
+		e = prev
+	}
+	c.mu.Unlock()
+
+	// Stopping blocks until all outstanding messages have been sent, so do it outside of the lock
+	for _, candidate := range evicted {
+		candidate.publisher.Stop()
+	}
+}
+
+// stop flushes and stops all publishers.
+func (c *publisherCache) stop() {
+	c.mu.Lock()
+	publishers := c.publishers
+	c.publishers = map[string]*cachedPublisher{}
+	c.lru.Init()
+	c.mu.Unlock()
+
+	for _, cp := range publishers {
+		cp.publisher.Stop()
+	}
+}
+
+func (h *Handler) respond(ctx context.Context, publisher *pubsub.Publisher, res *Response, orderingKey string) (err error) {
+	logger := logging.Ctx(ctx)
+	logger.Debug().Interface("gorch_response", res).Msg("Sending response")
+
+	attrs := []attribute.KeyValue{
+		AttributeResponseTopic.String(publisher.ID()),
+		AttributeResultCode.String(string(res.ResultCode)),
+	}
+	start := time.Now()
+
+	ctx, span := startSpan(ctx, SpanNamePublish, attrs...)
+	defer func() {
+		instrumentsFromCtx(ctx).publishDuration.Record(ctx, seconds(start), metric.WithAttributes(attrs...))
+		endSpan(span, err)
+	}()
+
+	enc, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+
+	// Inject the current trace context, so that the Platform Orchestrator can continue the trace
+	attributes := map[string]string{}
+	h.propagator.Inject(ctx, propagation.MapCarrier(attributes))
+
+	backoff := h.retry.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		publishResult := publisher.Publish(ctx, &pubsub.Message{
+			Data:        enc,
+			Attributes:  attributes,
+			OrderingKey: orderingKey,
+		})
+		_, err = publishResult.Get(ctx)
+		if err == nil || attempt >= h.retry.MaxAttempts {
+			return err
+		}
+
+		logger.Warn().Err(err).Int("gorch_publish_attempt", attempt).Msgf("Encountered an error when publishing the Response, retrying in %s", backoff)
+
+		// Publishing is paused for an ordering key after a failure, and has to be resumed before retrying
+		if orderingKey != "" {
+			publisher.ResumePublish(orderingKey)
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if h.retry.MaxBackoff > 0 {
+			backoff = min(backoff, h.retry.MaxBackoff)
+		}
+	}
+}
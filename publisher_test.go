@@ -0,0 +1,271 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/v2/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// newUnreachablePubSubClient returns a client for an emulator which does not exist.
+// Publishers can still be created and stopped, as long as nothing is published.
+func newUnreachablePubSubClient(t *testing.T) *pubsub.Client {
+	t.Helper()
+	t.Setenv("PUBSUB_EMULATOR_HOST", "localhost:1")
+
+	client, err := pubsub.NewClient(context.Background(), "mockproject")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return client
+}
+
+// failingPublishReactor fails the first publish requests sent to a fake Pub/Sub server, and counts all of them.
+type failingPublishReactor struct {
+	failures int32
+	calls    atomic.Int32
+}
+
+func (r *failingPublishReactor) React(_ any) (bool, any, error) {
+	if r.calls.Add(1) <= r.failures {
+		// Not retried by the Pub/Sub client itself, only by the handler
+		return true, nil, status.Error(codes.PermissionDenied, "mock error")
+	}
+	return false, nil, nil
+}
+
+// newFakePubSubClient returns a client for a fake Pub/Sub server, on which the given topic exists.
+func newFakePubSubClient(t *testing.T, topic string, opts ...pstest.ServerReactorOption) (*pstest.Server, *pubsub.Client) {
+	t.Helper()
+
+	srv := pstest.NewServer(opts...)
+	t.Cleanup(func() {
+		_ = srv.Close()
+	})
+
+	client, err := pubsub.NewClient(context.Background(), "mockproject",
+		option.WithEndpoint(srv.Addr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	_, err = client.TopicAdminClient.CreateTopic(context.Background(), &pubsubpb.Topic{Name: fmt.Sprintf("projects/mockproject/topics/%s", topic)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return srv, client
+}
+
+func TestHandlerPublishRetry(t *testing.T) {
+	tests := []struct {
+		title     string
+		failures  int32
+		ordering  bool
+		wantErr   bool
+		wantCalls int32
+	}{
+		{
+			title:     "Success",
+			wantCalls: 1,
+		},
+		{
+			title:     "Retried until success",
+			failures:  2,
+			wantCalls: 3,
+		},
+		{
+			title:     "Retried until success with ordering keys",
+			failures:  2,
+			ordering:  true,
+			wantCalls: 3,
+		},
+		{
+			title:     "Retried until max attempts",
+			failures:  5,
+			wantErr:   true,
+			wantCalls: 3,
+		},
+	}
+
+	for _, test := range tests {
+		tmp := test
+		t.Run(tmp.title, func(t *testing.T) {
+			t.Parallel()
+
+			reactor := &failingPublishReactor{failures: tmp.failures}
+			srv, client := newFakePubSubClient(t, DefaultMockResponseTopic, pstest.ServerReactorOption{FuncName: "Publish", Reactor: reactor})
+
+			mh := &testHandler{
+				action: func(_ context.Context, _ Request, r *Result) error {
+					r.Succeed("")
+					return nil
+				},
+			}
+			so := &testSO{handlers: []ManifestHandler{mh}}
+
+			opts := []HandlerOption{
+				withTestLogger(),
+				WithCustomPubSubClient(client),
+				WithPublishRetryPolicy(PublishRetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}),
+			}
+			if tmp.ordering {
+				opts = append(opts, WithOrderingKeys())
+			}
+			h, err := NewHandler(so, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				_ = h.Close(context.Background())
+			})
+
+			e, err := NewMockCloudEvent(newTestManifest(mh))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = h.Handle(context.Background(), *e)
+			if (err != nil) != tmp.wantErr {
+				t.Errorf("handler error does not match expected value\ngot: %v\nwant error: %t", err, tmp.wantErr)
+			}
+			if reactor.calls.Load() != tmp.wantCalls {
+				t.Errorf("number of publish attempts does not match expected value\ngot: %d\nwant: %d", reactor.calls.Load(), tmp.wantCalls)
+			}
+
+			messages := srv.Messages()
+			if tmp.wantErr {
+				if len(messages) != 0 {
+					t.Errorf("response was published, even though all attempts failed")
+				}
+				return
+			}
+			if len(messages) != 1 {
+				t.Fatalf("number of published responses does not match expected value\ngot: %d\nwant: %d", len(messages), 1)
+			}
+
+			wantKey := ""
+			if tmp.ordering {
+				wantKey = DefaultMockContextID
+			}
+			if messages[0].OrderingKey != wantKey {
+				t.Errorf("response ordering key does not match expected value\ngot: %q\nwant: %q", messages[0].OrderingKey, wantKey)
+			}
+		})
+	}
+}
+
+func TestPublisherCache(t *testing.T) {
+	cache := newPublisherCache(newUnreachablePubSubClient(t), 2, false)
+
+	a := cache.acquire("a")
+	b := cache.acquire("b")
+	c := cache.acquire("c")
+	if a == b || b == c {
+		t.Fatalf("publishers for different topics are not unique")
+	}
+
+	// Releasing the least recently used publisher makes it idle, so it is evicted to get back within the bound
+	cache.release(a)
+	if len(cache.publishers) != 2 {
+		t.Fatalf("number of cached publishers does not match expected value\ngot: %d\nwant: %d", len(cache.publishers), 2)
+	}
+	if _, ok := cache.publishers["a"]; ok {
+		t.Errorf("least recently used idle publisher was not evicted")
+	}
+
+	// Reacquiring a cached publisher should reuse it
+	b2 := cache.acquire("b")
+	if b2 != b {
+		t.Errorf("cached publisher was not reused")
+	}
+
+	cache.release(b)
+	cache.release(b2)
+	cache.release(c)
+	if len(cache.publishers) != 2 {
+		t.Errorf("number of cached publishers does not match expected value\ngot: %d\nwant: %d", len(cache.publishers), 2)
+	}
+
+	cache.stop()
+	if len(cache.publishers) != 0 {
+		t.Errorf("publishers were not removed when stopping the cache")
+	}
+}
+
+func TestHandlerClose(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+
+	mh := &testHandler{
+		action: func(_ context.Context, _ Request, r *Result) error {
+			close(started)
+			<-unblock
+			r.Succeed("")
+			return nil
+		},
+	}
+	so := &testSO{handlers: []ManifestHandler{mh}}
+
+	h, err := NewHandler(so, withTestLogger(), WithCustomPubSubClient(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewMockCloudEvent(newTestManifest(mh))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handled := make(chan error)
+	go func() {
+		handled <- h.Handle(context.Background(), *e)
+	}()
+	<-started
+
+	// Close should time out while an event is still being handled
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = h.Close(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("closing handler with in-flight events did not time out\ngot: %v", err)
+	}
+
+	// New events should be rejected once the handler is closing
+	err = h.Handle(context.Background(), *e)
+	if !errors.Is(err, ErrHandlerClosed) {
+		t.Errorf("closed handler did not reject new events\ngot: %v\nwant: %s", err, ErrHandlerClosed)
+	}
+
+	close(unblock)
+	err = <-handled
+	if err != nil {
+		t.Errorf("in-flight event returned non-nil error\ngot: %s", err)
+	}
+
+	err = h.Close(context.Background())
+	if err != nil {
+		t.Errorf("closing handler returned non-nil error\ngot: %s", err)
+	}
+}
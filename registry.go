@@ -0,0 +1,105 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// -----------------------
+// Handler Registry
+// -----------------------
+
+// ErrInvalidOrchestrator is wrapped by all errors caused by an invalid sub-orchestrator configuration.
+var ErrInvalidOrchestrator = errors.New("invalid orchestrator")
+
+// Constraints on manifest apiVersions and kinds, matching the ManifestHeader schema.
+var manifestAPIVersionPattern = regexp.MustCompile(`^orchestrator\.entur\.io\/.*\/[vV].*$`)
+
+const (
+	manifestAPIVersionMaxLength = 2083
+	manifestKindMinLength       = 2
+	manifestKindMaxLength       = 63
+)
+
+// handlerRegistry indexes the manifest handlers of a sub-orchestrator by their APIVersion and Kind.
+type handlerRegistry struct {
+	handlers []ManifestHandler // In the order returned by the sub-orchestrator, used for suggestions
+	index    map[ManifestHeader]ManifestHandler
+}
+
+// newHandlerRegistry indexes the given handlers. If several handlers share an APIVersion and Kind, the first one is used.
+func newHandlerRegistry(handlers []ManifestHandler) *handlerRegistry {
+	registry := &handlerRegistry{
+		handlers: make([]ManifestHandler, 0, len(handlers)),
+		index:    make(map[ManifestHeader]ManifestHandler, len(handlers)),
+	}
+
+	for _, handler := range handlers {
+		if handler == nil {
+			continue
+		}
+
+		registry.handlers = append(registry.handlers, handler)
+		key := ManifestHeader{APIVersion: handler.APIVersion(), Kind: handler.Kind()}
+		if _, ok := registry.index[key]; !ok {
+			registry.index[key] = handler
+		}
+	}
+
+	return registry
+}
+
+func (r *handlerRegistry) lookup(header ManifestHeader) (ManifestHandler, bool) {
+	handler, ok := r.index[header]
+	return handler, ok
+}
+
+// ValidateOrchestrator checks that all manifest handlers of the sub-orchestrator have a valid APIVersion and Kind,
+// and that no two handlers share the same APIVersion and Kind. All problems are reported at once.
+func ValidateOrchestrator(so Orchestrator) error {
+	if so == nil {
+		return fmt.Errorf("%w: orchestrator is nil", ErrInvalidOrchestrator)
+	}
+
+	var errs []error
+	seen := map[ManifestHeader]int{}
+
+	for i, handler := range so.Handlers() {
+		if handler == nil {
+			errs = append(errs, fmt.Errorf("handler %d is nil", i))
+			continue
+		}
+
+		version := handler.APIVersion()
+		kind := handler.Kind()
+
+		switch {
+		case version == "":
+			errs = append(errs, fmt.Errorf("handler %d (kind '%s') has an empty apiVersion", i, kind))
+		case len(version) > manifestAPIVersionMaxLength:
+			errs = append(errs, fmt.Errorf("handler %d (kind '%s') has an apiVersion longer than %d characters", i, kind, manifestAPIVersionMaxLength))
+		case !manifestAPIVersionPattern.MatchString(string(version)):
+			errs = append(errs, fmt.Errorf("handler %d (kind '%s') has apiVersion '%s' which does not match the pattern '%s'", i, kind, version, manifestAPIVersionPattern))
+		}
+
+		switch {
+		case kind == "":
+			errs = append(errs, fmt.Errorf("handler %d (apiVersion '%s') has an empty kind", i, version))
+		case len(kind) < manifestKindMinLength || len(kind) > manifestKindMaxLength:
+			errs = append(errs, fmt.Errorf("handler %d (apiVersion '%s') has kind '%s' which is not between %d and %d characters", i, version, kind, manifestKindMinLength, manifestKindMaxLength))
+		}
+
+		key := ManifestHeader{APIVersion: version, Kind: kind}
+		if first, ok := seen[key]; ok {
+			errs = append(errs, fmt.Errorf("handler %d has the same apiVersion '%s' and kind '%s' as handler %d", i, version, kind, first))
+		} else {
+			seen[key] = i
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidOrchestrator, errors.Join(errs...))
+	}
+	return nil
+}
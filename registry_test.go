@@ -0,0 +1,110 @@
+package orchestrator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type namedTestHandler struct {
+	testHandler
+	version APIVersion
+	kind    Kind
+}
+
+func (h *namedTestHandler) APIVersion() APIVersion { return h.version }
+func (h *namedTestHandler) Kind() Kind             { return h.kind }
+
+func TestValidateOrchestrator(t *testing.T) {
+	type Expected struct {
+		valid    bool
+		contains []string
+	}
+
+	type Test struct {
+		title    string
+		handlers []ManifestHandler
+		expected Expected
+	}
+
+	var tests = []Test{
+		{
+			title: "valid handlers",
+			handlers: []ManifestHandler{
+				&namedTestHandler{version: "orchestrator.entur.io/test/v1", kind: "AA"},
+				&namedTestHandler{version: "orchestrator.entur.io/test/v1", kind: "BB"},
+			},
+			expected: Expected{valid: true},
+		},
+		{
+			title: "all problems are reported together",
+			handlers: []ManifestHandler{
+				&namedTestHandler{version: "orchestrator.entur.io/test/v1", kind: "Test"},
+				nil,
+				&namedTestHandler{version: "", kind: ""},
+				&namedTestHandler{version: "example.com/test/v1", kind: "X"},
+				&namedTestHandler{version: "orchestrator.entur.io/test/v1", kind: "Test"},
+			},
+			expected: Expected{
+				contains: []string{
+					"handler 1 is nil",
+					"handler 2 (kind '') has an empty apiVersion",
+					"handler 2 (apiVersion '') has an empty kind",
+					"handler 3 (kind 'X') has apiVersion 'example.com/test/v1' which does not match the pattern",
+					"handler 3 (apiVersion 'example.com/test/v1') has kind 'X' which is not between 2 and 63 characters",
+					"handler 4 has the same apiVersion 'orchestrator.entur.io/test/v1' and kind 'Test' as handler 0",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		tmp := test
+		t.Run(tmp.title, func(t *testing.T) {
+			t.Parallel()
+
+			so := &testSO{handlers: tmp.handlers}
+			err := ValidateOrchestrator(so)
+			if tmp.expected.valid {
+				if err != nil {
+					t.Fatalf("valid orchestrator was rejected\ngot: %s", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrInvalidOrchestrator) {
+				t.Fatalf("invalid orchestrator was not rejected\ngot: %v\nwant: %s", err, ErrInvalidOrchestrator)
+			}
+			for _, str := range tmp.expected.contains {
+				if !strings.Contains(err.Error(), str) {
+					t.Errorf("validation error does not contain expected value\ngot: %s\nwant: %s", err, str)
+				}
+			}
+
+			_, err = NewHandler(so, withTestLogger(), WithCustomPubSubClient(nil))
+			if !errors.Is(err, ErrInvalidOrchestrator) {
+				t.Errorf("handler was created for an invalid orchestrator\ngot: %v\nwant: %s", err, ErrInvalidOrchestrator)
+			}
+		})
+	}
+}
+
+func TestHandlerRegistry(t *testing.T) {
+	first := &namedTestHandler{version: "orchestrator.entur.io/test/v1", kind: "Test"}
+	shadowed := &namedTestHandler{version: "orchestrator.entur.io/test/v1", kind: "Test"}
+
+	registry := newHandlerRegistry([]ManifestHandler{first, nil, shadowed})
+	if len(registry.handlers) != 2 {
+		t.Errorf("number of registered handlers does not match expected value\ngot: %d\nwant: %d", len(registry.handlers), 2)
+	}
+
+	handler, ok := registry.lookup(newTestManifest(first))
+	if !ok || handler != first {
+		t.Errorf("duplicate handler did not resolve to the first registered handler")
+	}
+
+	_, ok = registry.lookup(ManifestHeader{APIVersion: first.APIVersion(), Kind: "Unknown"})
+	if ok {
+		t.Errorf("lookup of an unknown kind returned a handler")
+	}
+}
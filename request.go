@@ -0,0 +1,140 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// -----------------------
+// Request Codecs
+// -----------------------
+
+// ErrInvalidRequest is wrapped by all errors caused by a malformed Platform Orchestrator Request envelope.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// The RequestCodec interface represents the decoding and validation of a specific Platform Orchestrator Request APIVersion.
+type RequestCodec interface {
+	// Decode the raw request into the common Request representation
+	Decode(data []byte) (*Request, error)
+	// Validate the request envelope, reporting all problems at once
+	Validate(req *Request) error
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[APIVersion]RequestCodec{
+		APIVersionOrchestratorRequestV1: RequestCodecV1{},
+	}
+)
+
+// RegisterRequestCodec registers the codec used for requests with the given APIVersion, replacing any existing codec.
+func RegisterRequestCodec(version APIVersion, codec RequestCodec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[version] = codec
+}
+
+func requestCodec(version APIVersion) (RequestCodec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	codec, ok := codecs[version]
+	if !ok {
+		versions := slices.Sorted(maps.Keys(codecs))
+		return nil, fmt.Errorf("%w: apiVersion '%s' is not supported, expected one of %s", ErrInvalidRequest, version, joinQuoted(versions))
+	}
+
+	return codec, nil
+}
+
+// DecodeRequest decodes a raw Platform Orchestrator Request using the codec registered for its APIVersion.
+func DecodeRequest(data []byte) (*Request, error) {
+	var header struct {
+		APIVersion APIVersion `json:"apiVersion"`
+	}
+
+	err := json.Unmarshal(data, &header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+
+	codec, err := requestCodec(header.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return codec.Decode(data)
+}
+
+// ValidateRequest validates the request envelope using the codec registered for its APIVersion.
+func ValidateRequest(req *Request) error {
+	codec, err := requestCodec(req.APIVersion)
+	if err != nil {
+		return err
+	}
+
+	return codec.Validate(req)
+}
+
+// decodeEnvelope leniently decodes the response topic and metadata of a request which could not be decoded or validated,
+// such that it can still be responded to. Fields which cannot be decoded are left empty.
+func decodeEnvelope(data []byte) (string, RequestMetadata) {
+	var responseTopic string
+	var metadata RequestMetadata
+
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return responseTopic, metadata
+	}
+
+	_ = json.Unmarshal(fields["responseTopic"], &responseTopic)
+	_ = json.Unmarshal(fields["metadata"], &metadata)
+	return responseTopic, metadata
+}
+
+// RequestCodecV1 decodes and validates 'orchestrator.entur.io/request/v1' requests.
+type RequestCodecV1 struct{}
+
+func (RequestCodecV1) Decode(data []byte) (*Request, error) {
+	var req Request
+
+	err := json.Unmarshal(data, &req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+
+	return &req, nil
+}
+
+func (RequestCodecV1) Validate(req *Request) error {
+	var errs []error
+
+	if !req.Action.IsValid() {
+		errs = append(errs, fmt.Errorf("action '%s' is not valid, expected one of %s", req.Action, joinQuoted(Actions())))
+	}
+	if req.ResponseTopic == "" {
+		errs = append(errs, fmt.Errorf("responseTopic is required"))
+	}
+	if len(req.Manifest.New) == 0 || string(req.Manifest.New) == "null" {
+		errs = append(errs, fmt.Errorf("manifest.new is required"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidRequest, errors.Join(errs...))
+	}
+	return nil
+}
+
+func joinQuoted[T ~string](values []T) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, fmt.Sprintf("'%s'", v))
+	}
+	return strings.Join(quoted, ", ")
+}
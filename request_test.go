@@ -0,0 +1,255 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	cloudevent "github.com/cloudevents/sdk-go/v2/event"
+)
+
+type countingSO struct {
+	testSO
+	calls int
+}
+
+func (so *countingSO) MiddlewareBefore(_ context.Context, _ Request, _ *Result) error {
+	so.calls++
+	return nil
+}
+
+func TestRequestValidation(t *testing.T) {
+	type Expected struct {
+		valid    bool
+		contains []string
+	}
+
+	type Test struct {
+		title    string
+		opts     []MockRequestOption
+		mutate   func(*Request)
+		expected Expected
+	}
+
+	var tests = []Test{
+		{
+			title:    "valid request",
+			expected: Expected{valid: true},
+		},
+		{
+			title:  "unsupported apiVersion",
+			mutate: func(req *Request) { req.APIVersion = "orchestrator.entur.io/request/v0" },
+			expected: Expected{
+				contains: []string{"apiVersion 'orchestrator.entur.io/request/v0' is not supported"},
+			},
+		},
+		{
+			title: "invalid action, missing topic and manifest",
+			opts:  []MockRequestOption{WithAction("yolo")},
+			mutate: func(req *Request) {
+				req.ResponseTopic = ""
+				req.Manifest.New = nil
+			},
+			expected: Expected{
+				contains: []string{
+					"action 'yolo' is not valid",
+					"responseTopic is required",
+					"manifest.new is required",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		tmp := test
+		t.Run(tmp.title, func(t *testing.T) {
+			t.Parallel()
+
+			mh := &testHandler{}
+			req, err := NewMockRequest(newTestManifest(mh), tmp.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tmp.mutate != nil {
+				tmp.mutate(req)
+			}
+
+			data, err := json.Marshal(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := DecodeRequest(data)
+			if err == nil {
+				err = ValidateRequest(decoded)
+			}
+
+			if tmp.expected.valid {
+				if err != nil {
+					t.Fatalf("valid request was rejected\ngot: %s", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrInvalidRequest) {
+				t.Fatalf("invalid request was not rejected\ngot: %v\nwant: %s", err, ErrInvalidRequest)
+			}
+			for _, str := range tmp.expected.contains {
+				if !strings.Contains(err.Error(), str) {
+					t.Errorf("validation error does not contain expected value\ngot: %s\nwant: %s", err, str)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleInvalidRequest(t *testing.T) {
+	type Expected struct {
+		published bool
+		contextID string
+	}
+
+	type Test struct {
+		title    string
+		mutate   func(map[string]any)
+		expected Expected
+	}
+
+	var tests = []Test{
+		{
+			title:    "unsupported apiVersion",
+			mutate:   func(req map[string]any) { req["apiVersion"] = "orchestrator.entur.io/request/v0" },
+			expected: Expected{published: true, contextID: DefaultMockContextID},
+		},
+		{
+			title:    "invalid action",
+			mutate:   func(req map[string]any) { req["action"] = "yolo" },
+			expected: Expected{published: true, contextID: DefaultMockContextID},
+		},
+		{
+			title:    "malformed field",
+			mutate:   func(req map[string]any) { req["sender"] = "mockuser" },
+			expected: Expected{published: true, contextID: DefaultMockContextID},
+		},
+		{
+			title:    "malformed metadata",
+			mutate:   func(req map[string]any) { req["metadata"] = 5 },
+			expected: Expected{published: true},
+		},
+		{
+			title:  "missing response topic",
+			mutate: func(req map[string]any) { delete(req, "responseTopic") },
+		},
+	}
+
+	for _, test := range tests {
+		tmp := test
+		t.Run(tmp.title, func(t *testing.T) {
+			t.Parallel()
+
+			srv, client := newFakePubSubClient(t, DefaultMockResponseTopic)
+
+			mh := &testHandler{}
+			so := &countingSO{testSO: testSO{handlers: []ManifestHandler{mh}}}
+			h, err := NewHandler(so, withTestLogger(), WithCustomPubSubClient(client))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				_ = h.Close(context.Background())
+			})
+
+			req, err := NewMockRequest(newTestManifest(mh))
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := json.Marshal(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields map[string]any
+			err = json.Unmarshal(data, &fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tmp.mutate(fields)
+			data, err = json.Marshal(fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			envelope, err := json.Marshal(CloudEventData{Message: PubSubMessage{Data: data}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := cloudevent.New(cloudevent.CloudEventsVersionV03)
+			e.DataEncoded = envelope
+
+			// Invalid requests are acknowledged, since they will never succeed
+			err = h.Handle(context.Background(), e)
+			if err != nil {
+				t.Fatalf("handler returned non-nil error\ngot: %s", err)
+			}
+			if so.calls != 0 {
+				t.Errorf("middleware was run for an invalid request")
+			}
+
+			messages := srv.Messages()
+			if !tmp.expected.published {
+				if len(messages) != 0 {
+					t.Errorf("response was published, even though the request has no response topic")
+				}
+				return
+			}
+			if len(messages) != 1 {
+				t.Fatalf("number of published responses does not match expected value\ngot: %d\nwant: %d", len(messages), 1)
+			}
+
+			var res Response
+			err = json.Unmarshal(messages[0].Data, &res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.ResultCode != ResultCodeError {
+				t.Errorf("result code does not match expected value\ngot: %s\nwant: %s", res.ResultCode, ResultCodeError)
+			}
+			if res.Metadata.ContextID != tmp.expected.contextID {
+				t.Errorf("response context id does not match expected value\ngot: %s\nwant: %s", res.Metadata.ContextID, tmp.expected.contextID)
+			}
+			if res.APIVersion != "orchestrator.entur.io/request/v1" {
+				t.Errorf("response apiVersion does not match expected value\ngot: %s\nwant: %s", res.APIVersion, "orchestrator.entur.io/request/v1")
+			}
+		})
+	}
+}
+
+func TestProcessInvalidRequest(t *testing.T) {
+	var called bool
+	mh := &testHandler{
+		action: func(_ context.Context, _ Request, r *Result) error {
+			called = true
+			r.Succeed("")
+			return nil
+		},
+	}
+	so := &testSO{handlers: []ManifestHandler{mh}}
+
+	// Requests passed directly to Process are validated as well, e.g. this one is missing an apiVersion and responseTopic
+	manifest, err := json.Marshal(newTestManifest(mh))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &Request{Action: ActionPlan, Manifest: Manifests{New: manifest}}
+
+	result := Process(context.Background(), so, req)
+	if result.Code() != ResultCodeError {
+		t.Errorf("result code does not match expected value\ngot: %s\nwant: %s", result.Code(), ResultCodeError)
+	}
+	if !errors.Is(errors.Join(result.errs...), ErrInvalidRequest) {
+		t.Errorf("result errors do not match expected value\ngot: %v\nwant: %s", result.errs, ErrInvalidRequest)
+	}
+	if called {
+		t.Errorf("manifest handler was run for an invalid request")
+	}
+}
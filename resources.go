@@ -0,0 +1,70 @@
+package orchestrator
+
+import (
+	"context"
+	"slices"
+
+	"github.com/entur/go-orchestrator/oresources"
+)
+
+// -----------------------
+// Resources
+// -----------------------
+
+// Shared by all calls to Process, so that clients are only created once per URL
+var defaultIAMLookupPool = oresources.NewIAMLookupPool()
+
+// Shared by all calls to Process, so that clients are only created once per name and URL
+var defaultClientPool = oresources.NewClientPool()
+
+// The resourceClients type attaches resource clients for the current request to the context.
+type resourceClients struct {
+	iamLookupPool *oresources.IAMLookupPool
+	pool          *oresources.ClientPool
+	overrides     map[string]any // Used for all requests regardless of their resources, by name
+}
+
+func (rc *resourceClients) attach(ctx context.Context, so Orchestrator, req *Request) context.Context {
+	var factories map[string]oresources.ClientFactory
+	if registrar, ok := so.(ResourceClients); ok {
+		factories = registrar.ResourceClients()
+	}
+
+	names := req.Resources.Names()
+	for name := range rc.overrides {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	// Clients are only created once they are retrieved from the context, since most handlers only use some of them
+	for _, name := range names {
+		// Clients attached by the caller take precedence, e.g. in tests calling Process directly
+		if oresources.HasClient(ctx, name) {
+			continue
+		}
+
+		if client, ok := rc.overrides[name]; ok {
+			ctx = oresources.WithClient(ctx, name, client)
+			continue
+		}
+
+		resource, _ := req.Resources.Get(name)
+		switch factory, ok := factories[name]; {
+		case ok:
+			ctx = oresources.WithLazyClient(ctx, name, func(ctx context.Context) (any, error) {
+				return rc.pool.Client(ctx, name, resource.URL, factory)
+			})
+		case name == oresources.ResourceIAMLookup:
+			ctx = oresources.WithLazyClient(ctx, name, func(ctx context.Context) (any, error) {
+				client, err := rc.iamLookupPool.Client(ctx, resource.URL)
+				if err != nil {
+					return nil, err
+				}
+				return client, nil
+			})
+		}
+	}
+
+	return ctx
+}
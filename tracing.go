@@ -0,0 +1,74 @@
+package orchestrator
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// -----------------------
+// Tracing
+// -----------------------
+
+const tracerName = "github.com/entur/go-orchestrator"
+
+// Span names used when tracing the processing pipeline.
+const (
+	SpanNameHandle                = "gorch.handle"
+	SpanNameUnmarshal             = "gorch.unmarshal"
+	SpanNameProcess               = "gorch.process"
+	SpanNameOrchestratorBefore    = "gorch.middleware.orchestrator.before"
+	SpanNameManifestHandlerBefore = "gorch.middleware.manifesthandler.before"
+	SpanNameManifestHandler       = "gorch.manifesthandler"
+	SpanNameManifestHandlerAfter  = "gorch.middleware.manifesthandler.after"
+	SpanNameOrchestratorAfter     = "gorch.middleware.orchestrator.after"
+	SpanNamePublish               = "gorch.publish"
+)
+
+// Attribute keys used when annotating spans.
+const (
+	AttributeAction        = attribute.Key("gorch.action")
+	AttributeAPIVersion    = attribute.Key("gorch.api_version")
+	AttributeKind          = attribute.Key("gorch.kind")
+	AttributeRepository    = attribute.Key("gorch.repository")
+	AttributeResultCode    = attribute.Key("gorch.result_code")
+	AttributeRequestID     = attribute.Key("gorch.request_id")
+	AttributeContextID     = attribute.Key("gorch.context_id")
+	AttributeResponseTopic = attribute.Key("gorch.response_topic")
+)
+
+// tracerFromCtx returns a tracer from the same provider as the span currently active in ctx.
+// If there is no active span, the globally registered provider is used instead.
+func tracerFromCtx(ctx context.Context) trace.Tracer {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		return span.TracerProvider().Tracer(tracerName)
+	}
+
+	return otel.GetTracerProvider().Tracer(tracerName)
+}
+
+func requestAttributes(req *Request) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		AttributeAction.String(string(req.Action)),
+		AttributeRepository.String(req.Origin.Repository.FullName),
+		AttributeRequestID.String(req.Metadata.RequestID),
+		AttributeContextID.String(req.Metadata.ContextID),
+	}
+}
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracerFromCtx(ctx).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan marks the span as failed if err is non-nil, and then ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,125 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) string {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestTracing(t *testing.T) {
+	const traceID = "0af7651916cd43dd8448eb211c80319c"
+
+	type Test struct {
+		title    string
+		err      error
+		expected []string
+		code     ResultCode
+	}
+
+	var tests = []Test{
+		{
+			title: "successful request",
+			expected: []string{
+				SpanNameUnmarshal,
+				SpanNameOrchestratorBefore,
+				SpanNameManifestHandler,
+				SpanNameProcess,
+				SpanNameHandle,
+			},
+			code: ResultCodeSuccess,
+		},
+		{
+			title: "failing manifest handler",
+			err:   fmt.Errorf("mock error"),
+			expected: []string{
+				SpanNameUnmarshal,
+				SpanNameOrchestratorBefore,
+				SpanNameManifestHandler,
+				SpanNameProcess,
+				SpanNameHandle,
+			},
+			code: ResultCodeError,
+		},
+	}
+
+	for _, test := range tests {
+		tmp := test
+		t.Run(tmp.title, func(t *testing.T) {
+			t.Parallel()
+
+			exporter := tracetest.NewInMemoryExporter()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+			mh := &testHandler{}
+			if tmp.err != nil {
+				mh.action = func(context.Context, Request, *Result) error {
+					return tmp.err
+				}
+			}
+			so := &testSO{handlers: []ManifestHandler{mh}}
+			handler := NewCloudEventHandler(so, withTestLogger(), WithCustomPubSubClient(nil), WithTracerProvider(tp))
+
+			req, err := NewMockRequest(newTestManifest(mh))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			e := newTestCloudEvent(t, req, CloudEventData{
+				Message: PubSubMessage{
+					Attributes: PubSubMessageAttributes{
+						"traceparent": fmt.Sprintf("00-%s-b7ad6b7169203331-01", traceID),
+					},
+				},
+			})
+			_ = handler(context.Background(), e)
+
+			spans := exporter.GetSpans()
+			if len(spans) != len(tmp.expected) {
+				t.Fatalf("total number of spans does not match expected value\ngot: %d\nwant: %d", len(spans), len(tmp.expected))
+			}
+
+			for i, span := range spans {
+				if span.Name != tmp.expected[i] {
+					t.Errorf("span name at index %d does not match expected value\ngot: %s\nwant: %s", i, span.Name, tmp.expected[i])
+				}
+				if span.SpanContext.TraceID().String() != traceID {
+					t.Errorf("span '%s' did not continue the propagated trace\ngot: %s\nwant: %s", span.Name, span.SpanContext.TraceID(), traceID)
+				}
+
+				switch span.Name {
+				case SpanNameProcess:
+					code := spanAttribute(span, AttributeResultCode)
+					if code != string(tmp.code) {
+						t.Errorf("span '%s' result code does not match expected value\ngot: %s\nwant: %s", span.Name, code, tmp.code)
+					}
+					kind := spanAttribute(span, AttributeKind)
+					if kind != string(mh.Kind()) {
+						t.Errorf("span '%s' kind does not match expected value\ngot: %s\nwant: %s", span.Name, kind, mh.Kind())
+					}
+				case SpanNameManifestHandler:
+					action := spanAttribute(span, AttributeAction)
+					if action != string(DefaultMockAction) {
+						t.Errorf("span '%s' action does not match expected value\ngot: %s\nwant: %s", span.Name, action, DefaultMockAction)
+					}
+					if tmp.err != nil && span.Status.Code != codes.Error {
+						t.Errorf("span '%s' status does not match expected value\ngot: %s\nwant: %s", span.Name, span.Status.Code, codes.Error)
+					}
+				}
+			}
+		})
+	}
+}
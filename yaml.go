@@ -0,0 +1,288 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------
+// YAML Manifests
+// -----------------------
+
+// The Position type represents a line and column in a manifest source file, both starting at 1.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// IsValid reports whether the position points at an actual location.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// The SourceMap type maps JSON pointers (RFC 6901, e.g. '/spec/owners/0') in a manifest to their position in the source file.
+// Mapping entries point at their key, and sequence items at the item itself.
+type SourceMap map[string]Position
+
+// ManifestFromYAML converts a YAML manifest into a JSON Manifest, and records the position of every field.
+// As JSON is valid YAML, JSON manifests can be converted as well. Scalars keep their source text where JSON allows it:
+// timestamps such as '2024-01-01' and octal-looking numbers such as '0755' are kept as strings, and numbers are kept
+// as written, e.g. '1.0' or '12345678901234567890'.
+func ManifestFromYAML(data []byte) (Manifest, SourceMap, error) {
+	var doc yaml.Node
+
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse manifest: %w", err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, nil, fmt.Errorf("manifest is empty")
+	}
+
+	value, err := jsonValue(&doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse manifest: %w", err)
+	}
+
+	manifest, err := json.Marshal(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to convert manifest to JSON: %w", err)
+	}
+
+	sources := SourceMap{}
+	root := doc.Content[0]
+	sources[""] = Position{Line: root.Line, Column: root.Column}
+	collectPositions(sources, "", root)
+
+	return manifest, sources, nil
+}
+
+// ReadManifestFile reads a YAML or JSON manifest file, see ManifestFromYAML.
+func ReadManifestFile(path string) (Manifest, SourceMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read manifest: %w", err)
+	}
+
+	manifest, sources, err := ManifestFromYAML(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return manifest, sources, nil
+}
+
+// Numbers which are written the same way in JSON, and can therefore be kept as is.
+var jsonNumberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// Integers with leading zeros, which YAML reads as octal, but are usually meant as strings such as file modes or ids.
+var octalLookingPattern = regexp.MustCompile(`^[-+]?0[0-9_]+$`)
+
+// jsonValue converts a YAML node into a value which json.Marshal renders with the source text of its scalars,
+// instead of decoding the node into Go values first, which would turn dates into timestamps and rewrite numbers.
+func jsonValue(node *yaml.Node) (any, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return jsonValue(node.Content[0])
+	case yaml.AliasNode:
+		return jsonValue(node.Alias)
+	case yaml.MappingNode:
+		return jsonObject(node)
+	case yaml.SequenceNode:
+		items := make([]any, 0, len(node.Content))
+		for _, item := range node.Content {
+			value, err := jsonValue(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return items, nil
+	case yaml.ScalarNode:
+		return jsonScalar(node)
+	default:
+		return nil, fmt.Errorf("line %d: unsupported yaml node", node.Line)
+	}
+}
+
+func jsonObject(node *yaml.Node) (map[string]any, error) {
+	object := map[string]any{}
+
+	// Keys defined in the mapping itself take precedence over merged ones, regardless of their order
+	var merged []map[string]any
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Kind == yaml.ScalarNode && key.Tag == "!!merge" {
+			sources := []*yaml.Node{value}
+			if resolved := resolveAlias(value); resolved.Kind == yaml.SequenceNode {
+				sources = resolved.Content
+			}
+			for _, source := range sources {
+				m, err := jsonValue(source)
+				if err != nil {
+					return nil, err
+				}
+				mo, ok := m.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("line %d: merged value is not a mapping", source.Line)
+				}
+				merged = append(merged, mo)
+			}
+			continue
+		}
+
+		v, err := jsonValue(value)
+		if err != nil {
+			return nil, err
+		}
+		object[key.Value] = v
+	}
+
+	// Earlier merged mappings take precedence over later ones
+	for _, m := range merged {
+		for k, v := range m {
+			if _, ok := object[k]; !ok {
+				object[k] = v
+			}
+		}
+	}
+
+	return object, nil
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.AliasNode {
+		return node.Alias
+	}
+	return node
+}
+
+func jsonScalar(node *yaml.Node) (any, error) {
+	switch node.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var b bool
+		err := node.Decode(&b)
+		return b, err
+	case "!!int", "!!float":
+		if jsonNumberPattern.MatchString(node.Value) {
+			return json.Number(node.Value), nil
+		}
+		if node.ShortTag() == "!!int" && octalLookingPattern.MatchString(node.Value) {
+			return node.Value, nil
+		}
+
+		// E.g. '0x1F', '1_000' or '.5', which JSON has no notation for
+		var f float64
+		err := node.Decode(&f)
+		if err != nil {
+			return nil, err
+		}
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, fmt.Errorf("line %d: '%s' cannot be represented in JSON", node.Line, node.Value)
+		}
+		return f, nil
+	default:
+		// Strings, timestamps, binary data and custom tags are all kept as written
+		return node.Value, nil
+	}
+}
+
+func collectPositions(sources SourceMap, pointer string, node *yaml.Node) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Value == "<<" {
+				// Merged mappings are reported at the position they were defined
+				continue
+			}
+
+			child := pointer + "/" + escapePointerToken(key.Value)
+			sources[child] = Position{Line: key.Line, Column: key.Column}
+			collectPositions(sources, child, node.Content[i+1])
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			child := pointer + "/" + strconv.Itoa(i)
+			sources[child] = Position{Line: item.Line, Column: item.Column}
+			collectPositions(sources, child, item)
+		}
+	}
+}
+
+func escapePointerToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// -----------------------
+// Manifest Field Errors
+// -----------------------
+
+// The ManifestFieldError type represents a problem with a specific field in the new manifest.
+// If the request was created from a manifest file, the error includes the position of the field.
+type ManifestFieldError struct {
+	Pointer  string   // JSON pointer to the field, e.g. '/spec/owners/0'
+	Position Position // Position of the field in the source file, if known
+	Err      error
+}
+
+func (e *ManifestFieldError) Error() string {
+	if e.Position.IsValid() {
+		return fmt.Sprintf("%s: %s: %s", e.Position, e.Pointer, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Pointer, e.Err)
+}
+
+func (e *ManifestFieldError) Unwrap() error {
+	return e.Err
+}
+
+// ManifestPosition returns the position of the given JSON pointer in the new manifest source file.
+// Positions are only known for requests created from a manifest file, such as with NewMockRequestFromFile.
+func (req Request) ManifestPosition(pointer string) (Position, bool) {
+	pos, ok := req.sources[pointer]
+	return pos, ok
+}
+
+// FieldError returns an error for the field at the given JSON pointer in the new manifest, including its position if known.
+func (req Request) FieldError(pointer string, err error) error {
+	pos, _ := req.ManifestPosition(pointer)
+	return &ManifestFieldError{Pointer: pointer, Position: pos, Err: err}
+}
+
+// AnnotateError converts errors caused by unmarshalling the new manifest into a ManifestFieldError,
+// so that it points at the offending field. Other errors are returned as is.
+func (req Request) AnnotateError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Field == "" {
+		return err
+	}
+
+	tokens := strings.Split(typeErr.Field, ".")
+	for i, token := range tokens {
+		tokens[i] = escapePointerToken(token)
+	}
+
+	return req.FieldError("/"+strings.Join(tokens, "/"), err)
+}
@@ -0,0 +1,172 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testYAMLManifest = `apiVersion: orchestrator.entur.io/test/v1
+kind: Test
+spec:
+  replicas: many
+  owners:
+    - team-a
+    - "team/b"
+`
+
+func TestManifestFromYAML(t *testing.T) {
+	manifest, sources, err := ManifestFromYAML([]byte(testYAMLManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var header ManifestHeader
+	err = json.Unmarshal(manifest, &header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Kind != "Test" {
+		t.Errorf("manifest kind does not match expected value\ngot: %s\nwant: %s", header.Kind, "Test")
+	}
+
+	expected := map[string]Position{
+		"":                {Line: 1, Column: 1},
+		"/kind":           {Line: 2, Column: 1},
+		"/spec/replicas":  {Line: 4, Column: 3},
+		"/spec/owners/0":  {Line: 6, Column: 7},
+		"/spec/owners/1":  {Line: 7, Column: 7},
+		"/spec/owners/99": {},
+	}
+	for pointer, want := range expected {
+		got := sources[pointer]
+		if got != want {
+			t.Errorf("position of '%s' does not match expected value\ngot: %s\nwant: %s", pointer, got, want)
+		}
+	}
+
+	_, _, err = ManifestFromYAML([]byte("kind: Test\n  spec: [\n"))
+	if err == nil || !strings.Contains(err.Error(), "line") {
+		t.Errorf("syntax error does not contain a line number\ngot: %v", err)
+	}
+}
+
+func TestManifestFromYAMLScalars(t *testing.T) {
+	type Test struct {
+		title    string
+		yaml     string
+		expected string
+	}
+
+	var tests = []Test{
+		{
+			title:    "dates and timestamps are kept as strings",
+			yaml:     "date: 2024-01-01\ntime: 2024-01-01T10:00:00+01:00\n",
+			expected: `{"date":"2024-01-01","time":"2024-01-01T10:00:00+01:00"}`,
+		},
+		{
+			title:    "numbers are kept as written",
+			yaml:     "float: 1.0\nlarge: 12345678901234567890\nexp: 1e3\nnegative: -5\n",
+			expected: `{"exp":1e3,"float":1.0,"large":12345678901234567890,"negative":-5}`,
+		},
+		{
+			title:    "octal-looking numbers are kept as strings",
+			yaml:     "mode: 0755\nid: 007\n",
+			expected: `{"id":"007","mode":"0755"}`,
+		},
+		{
+			title:    "numbers without a json notation are converted",
+			yaml:     "hex: 0x1F\nseparated: 1_000\nfraction: .5\n",
+			expected: `{"fraction":0.5,"hex":31,"separated":1000}`,
+		},
+		{
+			title:    "strings, booleans and nulls",
+			yaml:     "quoted: \"2024-01-01\"\nbool: true\nnull: ~\nlist: [yes, '1']\n",
+			expected: `{"bool":true,"list":["yes","1"],"null":null,"quoted":"2024-01-01"}`,
+		},
+		{
+			title:    "anchors and merge keys",
+			yaml:     "base: &base\n  a: 1\n  b: 2\nderived:\n  <<: *base\n  b: 3\n",
+			expected: `{"base":{"a":1,"b":2},"derived":{"a":1,"b":3}}`,
+		},
+	}
+
+	for _, test := range tests {
+		tmp := test
+		t.Run(tmp.title, func(t *testing.T) {
+			t.Parallel()
+
+			manifest, _, err := ManifestFromYAML([]byte(tmp.yaml))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(manifest) != tmp.expected {
+				t.Errorf("manifest does not match expected value\ngot: %s\nwant: %s", manifest, tmp.expected)
+			}
+		})
+	}
+
+	_, _, err := ManifestFromYAML([]byte("value: .inf\n"))
+	if err == nil {
+		t.Errorf("infinite number was converted to JSON")
+	}
+}
+
+func TestManifestFieldErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.yaml")
+	err := os.WriteFile(path, []byte(testYAMLManifest), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Spec struct {
+		Replicas int `json:"replicas"`
+	}
+
+	mh := &testHandler{
+		action: func(_ context.Context, req Request, r *Result) error {
+			var manifest struct {
+				Spec Spec `json:"spec"`
+			}
+			err := json.Unmarshal(req.Manifest.New, &manifest)
+			if err != nil {
+				r.Fail(req.AnnotateError(err).Error())
+				return nil
+			}
+			r.Succeed("")
+			return nil
+		},
+	}
+
+	req, err := NewMockRequestFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := Process(context.Background(), &testSO{handlers: []ManifestHandler{mh}}, req)
+	want := "line 4, column 3: /spec/replicas: "
+	if !strings.HasPrefix(result.Output(), want) {
+		t.Errorf("result output does not match expected value\ngot: %s\nwant: %s...", result.Output(), want)
+	}
+
+	err = req.FieldError("/spec/owners/1", errors.New("is not a valid team"))
+	want = "line 7, column 7: /spec/owners/1: is not a valid team"
+	if err.Error() != want {
+		t.Errorf("field error does not match expected value\ngot: %s\nwant: %s", err, want)
+	}
+
+	// Requests without a source file only know the pointer
+	req, err = NewMockRequest(newTestManifest(mh))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = req.FieldError("/spec", errors.New("is required"))
+	want = "/spec: is required"
+	if err.Error() != want {
+		t.Errorf("field error does not match expected value\ngot: %s\nwant: %s", err, want)
+	}
+}